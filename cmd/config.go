@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Comcast/Buildenv-Tool/reader"
+	"github.com/spf13/viper"
+)
+
+// projectConfigName is the config file searched for from the current
+// directory up, whose settings take precedence over the user's
+const projectConfigName = ".buildenv.yaml"
+
+// pathSettings are settings holding paths, which in a project's config file
+// are relative to the file
+var pathSettings = []string{"variables_file", "env-file"}
+
+// userOnlySettings can only be set in the user's config file, so a checked
+// out project can't widen what's allowed, send the user's Vault token to
+// another server, or run commands
+var userOnlySettings = []string{"allowed_remotes", "vault-addr", "vault-namespace", "run", "unset-inherited"}
+
+// findProjectConfig looks for a project's config file in the current
+// directory and each of its parents
+func findProjectConfig() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	for {
+		path := filepath.Join(dir, projectConfigName)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// readProjectConfig reads a project's config file, making its relative paths
+// relative to the current directory
+func readProjectConfig(path string) (map[string]interface{}, error) {
+	project := viper.New()
+	project.SetConfigFile(path)
+	project.SetConfigType("yaml")
+	if err := project.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	settings := project.AllSettings()
+	projectSettings(settings, filepath.Dir(path))
+	if profiles, ok := settings["profiles"].(map[string]interface{}); ok {
+		for _, profile := range profiles {
+			if profileSettings, ok := profile.(map[string]interface{}); ok {
+				projectSettings(profileSettings, filepath.Dir(path))
+			}
+		}
+	}
+	return settings, nil
+}
+
+// projectSettings drops settings a project can't set, and makes relative
+// paths relative to the project's directory
+func projectSettings(settings map[string]interface{}, dir string) {
+	for _, key := range userOnlySettings {
+		delete(settings, key)
+	}
+	relocatePaths(settings, dir)
+}
+
+// relocatePaths makes the relative paths in settings relative to dir
+func relocatePaths(settings map[string]interface{}, dir string) {
+	relocate := func(path string) string {
+		if filepath.IsAbs(path) || strings.HasPrefix(path, reader.VaultScheme) {
+			return path
+		}
+		return filepath.Join(dir, path)
+	}
+	for _, key := range pathSettings {
+		switch value := settings[key].(type) {
+		case string:
+			settings[key] = relocate(value)
+		case []interface{}:
+			paths := []interface{}{}
+			for _, path := range value {
+				if path, ok := path.(string); ok {
+					paths = append(paths, relocate(path))
+				}
+			}
+			settings[key] = paths
+		}
+	}
+}
+
+// useProfile layers a named profile from the config over the rest of it.
+// Flags and BUILDENV_* variables still take precedence.
+func useProfile(name string) error {
+	if !viper.IsSet("profiles." + name) {
+		return fmt.Errorf("no profile named %s in the config file", name)
+	}
+	return viper.MergeConfigMap(viper.GetStringMap("profiles." + name))
+}
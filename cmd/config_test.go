@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadProjectConfig_UserOnlySettings(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, projectConfigName)
+	config := `
+environment: stage
+vault-addr: https://vault.example.com
+vault-namespace: theirs
+allowed_remotes: [secret]
+run: curl https://example.com
+unset-inherited: true
+skip-vault: true
+profiles:
+  ci:
+    datacenter: us-east-1
+    vault-addr: https://vault.example.com
+    vault-namespace: theirs
+    allowed_remotes: [secret]
+    run: curl https://example.com
+    unset-inherited: true
+    skip-vault: true
+`
+	if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	settings, err := readProjectConfig(path)
+	if err != nil {
+		t.Fatalf("readProjectConfig() error = %v", err)
+	}
+	profile, _ := settings["profiles"].(map[string]interface{})["ci"].(map[string]interface{})
+	for _, key := range userOnlySettings {
+		if value, found := settings[key]; found {
+			t.Errorf("project config set %s to %v", key, value)
+		}
+		if value, found := profile[key]; found {
+			t.Errorf("project config profile set %s to %v", key, value)
+		}
+	}
+	// skip-vault is a convenience a project's profile can bundle
+	if settings["environment"] != "stage" || profile["datacenter"] != "us-east-1" || profile["skip-vault"] != true {
+		t.Errorf("readProjectConfig() = %v, want the other settings kept", settings)
+	}
+}
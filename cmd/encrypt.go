@@ -0,0 +1,100 @@
+/*
+Copyright © 2023 Comcast Cable Communications Management, LLC
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/Comcast/Buildenv-Tool/reader"
+	"github.com/spf13/cobra"
+)
+
+// encryptCmd produces an `!encrypted` value for use in vars
+var encryptCmd = &cobra.Command{
+	Use:   "encrypt [value]",
+	Short: "Encrypt a value for use as an !encrypted variable",
+	Long: `Encrypt a value with age for the given recipients and print it with the
+!encrypted tag, ready to paste into the vars of a variables file. The value
+is read from standard input when not given as an argument.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var plain string
+		if len(args) > 0 {
+			plain = args[0]
+		} else {
+			in, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				fmt.Printf("Unable to read value: %v", err)
+				os.Exit(ErrorCodeInput)
+			}
+			plain = strings.TrimSuffix(string(in), "\n")
+		}
+
+		recipients := []age.Recipient{}
+		recipientArgs, _ := cmd.Flags().GetStringArray("recipient")
+		for _, arg := range recipientArgs {
+			parsed, err := age.ParseRecipients(strings.NewReader(arg))
+			if err != nil {
+				fmt.Printf("Invalid recipient %q: %v", arg, err)
+				os.Exit(ErrorCodeInput)
+			}
+			recipients = append(recipients, parsed...)
+		}
+
+		recipientsFile, _ := cmd.Flags().GetString("recipients-file")
+		if recipientsFile != "" {
+			f, err := os.Open(recipientsFile)
+			if err != nil {
+				fmt.Printf("Unable to read recipients file %s: %v", recipientsFile, err)
+				os.Exit(ErrorCodeInput)
+			}
+			parsed, err := age.ParseRecipients(f)
+			f.Close()
+			if err != nil {
+				fmt.Printf("Unable to parse recipients file %s: %v", recipientsFile, err)
+				os.Exit(ErrorCodeInput)
+			}
+			recipients = append(recipients, parsed...)
+		}
+
+		usePassphrase, _ := cmd.Flags().GetBool("passphrase")
+		if usePassphrase {
+			passphrase := os.Getenv(reader.AgePassphraseEnv)
+			if passphrase == "" {
+				fmt.Printf("%s must be set to encrypt with a passphrase", reader.AgePassphraseEnv)
+				os.Exit(ErrorCodeInput)
+			}
+			recipient, err := age.NewScryptRecipient(passphrase)
+			if err != nil {
+				fmt.Printf("Unable to use passphrase: %v", err)
+				os.Exit(ErrorCodeInput)
+			}
+			recipients = append(recipients, recipient)
+		}
+
+		if len(recipients) == 0 {
+			fmt.Printf("At least one recipient (-R) or --passphrase is required")
+			os.Exit(ErrorCodeInput)
+		}
+
+		encrypted, err := reader.EncryptValue(plain, recipients...)
+		if err != nil {
+			fmt.Printf("Failure encrypting value: %v", err)
+			os.Exit(ErrorCodeOutput)
+		}
+		fmt.Printf("%s %s\n", reader.EncryptedTag, encrypted)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(encryptCmd)
+
+	encryptCmd.Flags().StringArrayP("recipient", "R", []string{}, "age recipient (public key) to encrypt to")
+	encryptCmd.Flags().String("recipients-file", "", "File of age recipients, one per line")
+	encryptCmd.Flags().BoolP("passphrase", "p", false, "Encrypt with the passphrase in "+reader.AgePassphraseEnv)
+}
@@ -0,0 +1,91 @@
+/*
+Copyright © 2023 Comcast Cable Communications Management, LLC
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Comcast/Buildenv-Tool/reader"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// lintCmd checks variables files for likely mistakes
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Check variables files for likely mistakes and plaintext secrets",
+	Long: `Check variables files for likely mistakes, such as variables set twice in
+one scope, datacenters missing from some environments, names that aren't
+valid in a shell and plain values that look like secrets.
+
+Rules can be disabled by ID or name with --disable, or in the config file:
+
+  lint:
+    disable: [BE004]
+
+Exits with an error if any finding has error severity.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		listRules, _ := cmd.Flags().GetBool("rules")
+		if listRules {
+			for _, rule := range reader.LintRules {
+				fmt.Printf("%s %-20s %-8s %s\n", rule.ID, rule.Name, rule.Severity, rule.Description)
+			}
+			return
+		}
+
+		variablesFiles, _ := cmd.Flags().GetStringArray("variables_file")
+		inputFormat, _ := cmd.Flags().GetString("input-format")
+		checkInputFormat(inputFormat)
+		data, err := reader.LoadVariablesUncheckedFormat(inputFormat, variablesFiles...)
+		if err != nil {
+			fmt.Printf("Failure loading variables: %v", err)
+			os.Exit(ErrorCodeYaml)
+		}
+
+		disabled, _ := cmd.Flags().GetStringArray("disable")
+		disabled = append(disabled, viper.GetStringSlice("lint.disable")...)
+		findings := reader.Lint(data, disabled)
+
+		format, _ := cmd.Flags().GetString("format")
+		switch format {
+		case "text":
+			for _, finding := range findings {
+				fmt.Println(finding)
+			}
+		case "json", "sarif":
+			var out []byte
+			if format == "json" {
+				out, err = reader.LintJSON(findings)
+			} else {
+				out, err = reader.LintSARIF(findings, Version)
+			}
+			if err != nil {
+				fmt.Printf("Failure printing output: %v", err)
+				os.Exit(ErrorCodeOutput)
+			}
+			fmt.Println(string(out))
+		default:
+			fmt.Printf("Unknown format %q, expected text, json or sarif", format)
+			os.Exit(ErrorCodeInput)
+		}
+
+		for _, finding := range findings {
+			if finding.Severity == reader.SeverityError {
+				os.Exit(ErrorCodeLint)
+			}
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+
+	lintCmd.Flags().StringArrayP("variables_file", "f", []string{"variables.yml"}, "Variables Source file (YAML, JSON, TOML or HCL), repeatable. A trailing ? marks a file as optional")
+	lintCmd.Flags().String("input-format", "", "Format of the variables files: yaml, json, toml or hcl (default is from the file extension, or yaml)")
+	lintCmd.Flags().String("format", "text", "Output format: text, json or sarif")
+	lintCmd.Flags().StringArray("disable", []string{}, "Disable a rule by ID or name (repeatable)")
+	lintCmd.Flags().Bool("rules", false, "List the rules and exit")
+}
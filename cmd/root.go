@@ -7,13 +7,17 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
 
 	"github.com/Comcast/Buildenv-Tool/reader"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
-	"gopkg.in/yaml.v3"
 )
 
 const (
@@ -29,6 +33,8 @@ const (
 	ErrorCodeInput = 7
 	// ErrorCodeOutput Exit Code for Failed Serialization/Output
 	ErrorCodeOutput = 8
+	// ErrorCodeLint Exit Code for Lint Errors
+	ErrorCodeLint = 9
 )
 
 var cfgFile string
@@ -41,9 +47,14 @@ var rootCmd = &cobra.Command{
 	Short: "Set environment variables from a configuation file",
 	Long: `Set environment variables based on environment and datacenter. 
 Values can be specified in plain text, or set from a vault server.`,
+	// Checked after the flags are parsed, so combined short flags such as
+	// -cf file aren't mistaken for a subcommand
+	Args: cobra.NoArgs,
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	Run: func(cmd *cobra.Command, args []string) {
+		// Not bound to the config, since CI systems often set BUILDENV_VERSION
+		// for the version of buildenv to install
 		version, _ := cmd.Flags().GetBool("version")
 		if version {
 			fmt.Printf("buildenv version %s\n", Version)
@@ -51,9 +62,9 @@ Values can be specified in plain text, or set from a vault server.`,
 		}
 
 		ctx := context.Background()
-		debug, _ := cmd.Flags().GetBool("debug")
+		debug := viper.GetBool("debug")
 
-		enableMlock, _ := cmd.Flags().GetBool("mlock")
+		enableMlock := viper.GetBool("mlock")
 		if enableMlock {
 			err := EnableMlock()
 			if err != nil {
@@ -62,101 +73,199 @@ Values can be specified in plain text, or set from a vault server.`,
 			}
 		}
 
-		// Read the Data File
-		variablesFile, _ := cmd.Flags().GetString("variables_file")
-		var data reader.Variables
+		skip_vault := viper.GetBool("skip-vault")
+		interpolate := viper.GetBool("interpolate")
+		interpolateEnv := viper.GetBool("interpolate-env")
+		templates := viper.GetBool("templates")
 
-		yamlFile, err := os.ReadFile(variablesFile)
-		if err != nil {
-			fmt.Printf("Unable to read file %s: %v", variablesFile, err)
-			os.Exit(ErrorCodeYaml)
+		shell := viper.GetString("shell")
+		if !reader.IsShell(shell) {
+			fmt.Printf("Failure reading shell: unknown shell %s, use one of %s", shell, strings.Join(reader.Shells(), ", "))
+			os.Exit(ErrorCodeInput)
 		}
-		err = yaml.Unmarshal(yamlFile, &data)
+
+		inputFormat := viper.GetString("input-format")
+		checkInputFormat(inputFormat)
+
+		// Values from the command line come after every scope
+		envFiles := viper.GetStringSlice("env-file")
+		fileOut, err := reader.EnvFiles(envFiles).GetOutput()
 		if err != nil {
-			fmt.Printf("Unable to parse YAML file %s: %v", variablesFile, err)
+			fmt.Printf("Failure reading env file: %v", err)
+			os.Exit(ErrorCodeInput)
 		}
-		if debug {
-			inData, _ := json.MarshalIndent(data, "", "  ")
-			fmt.Printf("Data:\n%s\n\n", inData)
+		cmdLineOut := fileOut
+
+		var use_vars reader.EnvVars
+
+		use := viper.GetStringSlice("use")
+		for _, use_inst := range use {
+			blob := os.Getenv(use_inst)
+			decoded := make([]byte, base64.StdEncoding.DecodedLen(len(blob)))
+			len, err := base64.StdEncoding.Decode(decoded, []byte(blob))
+			if err != nil {
+				fmt.Printf("Could not decode input to flag \"use\" (-u): %v", err)
+				os.Exit(ErrorCodeInput)
+			}
+			decoded = decoded[:len]
+			/* It adds to the structure, merging matching keys */
+			err = json.Unmarshal([]byte(decoded), &use_vars)
+			if err != nil {
+				fmt.Printf("Could not decode input to flag \"use\" (-u): %v", err)
+				os.Exit(ErrorCodeInput)
+			}
 		}
 
-		skip_vault, _ := cmd.Flags().GetBool("skip-vault")
+		vars_out := use_vars.GetOutput()
+		for i := range vars_out {
+			vars_out[i].Origin = "-u"
+		}
+		cmdLineOut = append(cmdLineOut, vars_out...)
 
 		// Setup the Reader
-		rdr, err := reader.NewReader(reader.WithSkipVault(skip_vault))
+		rdr, err := reader.NewReader(
+			reader.WithSkipVault(skip_vault),
+			reader.WithInterpolation(interpolate || interpolateEnv),
+			reader.WithHostEnv(interpolateEnv),
+			reader.WithTemplates(templates),
+			reader.WithAllowedRemotes(viper.GetStringSlice("allowed_remotes")),
+			reader.WithVaultAddress(viper.GetString("vault-addr")),
+			reader.WithVaultNamespace(viper.GetString("vault-namespace")),
+			reader.WithInputFormat(inputFormat),
+			reader.WithCommandLine(cmdLineOut),
+		)
 		if err != nil {
 			fmt.Printf("Failure creating Reader: %v", err)
 			os.Exit(ErrorCodeVault)
 		}
 
-		// Get the Output
-		env, _ := cmd.Flags().GetString("environment")
-		run, _ := cmd.Flags().GetString("run")
-		dc, _ := cmd.Flags().GetString("datacenter")
+		// Read the Data Files, later files taking precedence. vault:// files
+		// are read with the Reader.
+		variablesFiles := viper.GetStringSlice("variables_file")
 
-		out, err := rdr.Read(ctx, &data, env, dc)
+		data, err := rdr.LoadVariables(ctx, variablesFiles...)
 		if err != nil {
-			fmt.Printf("Failure reading data: %v", err)
-			os.Exit(ErrorCodeVault)
+			fmt.Printf("Failure loading variables: %v", err)
+			os.Exit(ErrorCodeYaml)
 		}
-
 		if debug {
-			outData, _ := json.MarshalIndent(out, "", "  ")
-			fmt.Printf("Output:\n%s\n\n", outData)
+			inData, _ := json.MarshalIndent(data, "", "  ")
+			fmt.Printf("Data:\n%s\n\n", inData)
 		}
 
-		var use_vars reader.EnvVars
+		// Get the Output
+		env := viper.GetString("environment")
+		dc := viper.GetString("datacenter")
 
-		use, err := cmd.Flags().GetStringArray("use")
+		// -e and -d select the environment and dc dimensions
+		scopes := viper.GetStringSlice("scope")
+		selection, err := reader.ParseSelection(scopes)
 		if err != nil {
-			fmt.Printf("Could not get \"use\" (-u) flag values: %v", err)
+			fmt.Printf("Failure reading scopes: %v", err)
 			os.Exit(ErrorCodeInput)
 		}
-
-		for _, use_inst := range use {
-			blob := os.Getenv(use_inst)
-			decoded := make([]byte, base64.StdEncoding.DecodedLen(len(blob)))
-			len, err := base64.StdEncoding.Decode(decoded, []byte(blob))
-			if err != nil {
-				fmt.Printf("Could not decode input to flag \"use\" (-u): %v", err)
-				os.Exit(ErrorCodeInput)
+		for dimension, name := range map[string]string{reader.EnvironmentDimension: env, reader.DCDimension: dc} {
+			if name == "" {
+				continue
 			}
-			decoded = decoded[:len]
-			/* It adds to the structure, merging matching keys */
-			err = json.Unmarshal([]byte(decoded), &use_vars)
-			if err != nil {
-				fmt.Printf("Could not decode input to flag \"use\" (-u): %v", err)
+			if selected, found := selection[dimension]; found && selected != name {
+				fmt.Printf("Failure reading scopes: %s selected as both %s and %s", dimension, selected, name)
 				os.Exit(ErrorCodeInput)
 			}
+			selection[dimension] = name
 		}
 
-		vars_out := use_vars.GetOutput()
-		out = append(out, vars_out...)
+		// Remove the files written for `files` values when interrupted, or
+		// when exiting without the output that refers to them
+		exit := func(code int) {
+			rdr.Cleanup()
+			os.Exit(code)
+		}
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		go exitOnSignal(signals, exit)
+
+		out, err := rdr.ReadScopes(ctx, data, selection)
+		if err != nil {
+			fmt.Printf("Failure reading data: %v", err)
+			if errors.Is(err, reader.ErrMissingHostVars) {
+				exit(ErrorCodeInput)
+			}
+			if errors.Is(err, reader.ErrInvalidSelection) {
+				exit(ErrorCodeEnv)
+			}
+			exit(ErrorCodeVault)
+		}
+
+		if debug {
+			outData, _ := json.MarshalIndent(out.Redacted(), "", "  ")
+			fmt.Printf("Output:\n%s\n\n", outData)
+			missingData, _ := json.MarshalIndent(rdr.Missing(), "", "  ")
+			fmt.Printf("Missing Optional Secrets:\n%s\n\n", missingData)
+		}
+
+		explain := viper.GetBool("explain")
+		if explain {
+			for _, explanation := range out.Explain() {
+				fmt.Println(explanation)
+			}
+			exit(0)
+		}
+
+		// Collapse variables set more than once, the narrowest scope winning
+		strictOverrides := viper.GetBool("strict-overrides")
+		out, err = out.Resolve(strictOverrides)
+		if err != nil {
+			fmt.Printf("Failure resolving variables: %v", err)
+			exit(ErrorCodeInput)
+		}
+		if debug {
+			outData, _ := json.MarshalIndent(out.Redacted(), "", "  ")
+			fmt.Printf("Resolved:\n%s\n\n", outData)
+		}
 
 		// Output the Exports
-		comments, _ := cmd.Flags().GetBool("comments")
+		comments := viper.GetBool("comments")
+		// Commands are only run when -r is given, never from the config or
+		// BUILDENV_RUN
 		if cmd.Flags().Lookup("run").Changed {
-			os.Exit(out.Exec(run))
+			run, _ := cmd.Flags().GetString("run")
+			unsetInherited := viper.GetBool("unset-inherited")
+			signal.Stop(signals)
+			close(signals)
+			exit(out.Exec(run, reader.WithUnsetInherited(unsetInherited)))
 		} else {
-			encoded_export, err := cmd.Flags().GetBool("export")
-			if err != nil {
-				fmt.Printf("Failure reading export flag: %v", err)
-				os.Exit(ErrorCodeInput)
-			}
-
+			encoded_export := viper.GetBool("export")
 			if encoded_export {
 				err = out.PrintB64Json()
 				if err != nil {
 					fmt.Printf("Failure printing output: %v", err)
-					os.Exit(ErrorCodeOutput)
+					exit(ErrorCodeOutput)
 				}
 			} else {
-				out.Print(comments)
+				out.Print(comments, reader.WithShell(shell))
 			}
 		}
 	},
 }
 
+// exitOnSignal exits with the usual code for the first signal received,
+// unless the channel is closed first. The read may still be running, so exit
+// must be safe to call alongside it.
+func exitOnSignal(signals <-chan os.Signal, exit func(int)) {
+	if sig, ok := <-signals; ok {
+		exit(128 + int(sig.(syscall.Signal)))
+	}
+}
+
+// checkInputFormat exits if --input-format isn't a format buildenv reads
+func checkInputFormat(format string) {
+	if format != "" && !slices.Contains(reader.InputFormats, format) {
+		fmt.Printf("Failure reading input format: unknown format %s, use one of %s", format, strings.Join(reader.InputFormats, ", "))
+		os.Exit(ErrorCodeInput)
+	}
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute(version string) {
@@ -174,25 +283,45 @@ func init() {
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.buildenv.yaml)")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.buildenv.yaml, and .buildenv.yaml in the project)")
+	rootCmd.PersistentFlags().String("profile", "", "Use the settings of a named profile from the config file")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
 	rootCmd.Flags().StringP("environment", "e", "", "Environment (qa, dev, stage, prod, etc)")
 	rootCmd.Flags().StringP("run", "r", "", "Shell command to execute with environment")
+	rootCmd.Flags().Bool("unset-inherited", false, "With -r, also remove unset variables inherited from the current environment")
 	rootCmd.Flags().StringP("datacenter", "d", "", "Datacenter (ndc_as_a, us-east-1 etc)")
-	rootCmd.Flags().StringP("variables_file", "f", "variables.yml", "Variables Source YAML file")
+	rootCmd.Flags().StringArray("scope", []string{}, "Select a scope as dimension=name, e.g. region=us-east (repeatable)")
+	rootCmd.Flags().StringArrayP("variables_file", "f", []string{"variables.yml"}, "Variables Source file (YAML, JSON, TOML or HCL) or vault:// path, repeatable with later files taking precedence. A trailing ? marks a file as optional")
+	rootCmd.Flags().String("input-format", "", "Format of the variables files: yaml, json, toml or hcl (default is from the file extension, or yaml)")
+	rootCmd.Flags().Bool("strict-overrides", false, "Fail if a variable is set in more than one scope instead of using the narrowest")
+	rootCmd.Flags().Bool("explain", false, "Print which file and scope set each variable instead of the exports")
 
 	rootCmd.Flags().BoolP("skip-vault", "v", false, "Skip Vault and use only variables file")
 	rootCmd.Flags().BoolP("mlock", "m", false, "Will enable system mlock if set (prevent write to swap on linux)")
 	rootCmd.Flags().BoolP("comments", "c", false, "Comments will be included in output")
+	rootCmd.Flags().String("shell", "sh", "Shell to quote the exports for: bash, sh or zsh")
 	rootCmd.Flags().Bool("debug", false, "Turn on debugging output")
 	rootCmd.Flags().Bool("version", false, "Print the version number")
 	rootCmd.Flags().StringArrayP("use", "u", []string{}, "Use Stored Vars from named environment variable. Contents should be base64 encoded JSON.")
 	rootCmd.Flags().BoolP("export", "x", false, "Print Vars as base64 encoded json")
+	rootCmd.Flags().StringArray("env-file", []string{}, "Read additional variables from a dotenv file")
+	rootCmd.Flags().BoolP("interpolate", "i", false, "Expand ${VAR} references between values")
+	rootCmd.Flags().Bool("interpolate-env", false, "Expand ${VAR} references, falling back to the current environment")
+	rootCmd.Flags().BoolP("templates", "t", false, "Render vars values as Go templates")
+	rootCmd.Flags().String("vault-addr", "", "Vault server address, in place of VAULT_ADDR")
+	rootCmd.Flags().String("vault-namespace", "", "Vault namespace, in place of VAULT_NAMESPACE")
+
+	// Every flag can also be set in the config file, a profile or a
+	// BUILDENV_* environment variable
+	cobra.CheckErr(viper.BindPFlags(rootCmd.Flags()))
+	cobra.CheckErr(viper.BindPFlag("profile", rootCmd.PersistentFlags().Lookup("profile")))
 }
 
-// initConfig reads in config file and ENV variables if set.
+// initConfig reads in config file and ENV variables if set. A project's
+// .buildenv.yaml, found from the current directory up, takes precedence over
+// the user's, and a profile over both.
 func initConfig() {
 	if cfgFile != "" {
 		// Use config file from the flag.
@@ -208,10 +337,34 @@ func initConfig() {
 		viper.SetConfigName(".buildenv")
 	}
 
+	// BUILDENV_SKIP_VAULT=true is the same as --skip-vault
+	viper.SetEnvPrefix("buildenv")
+	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_", ".", "_"))
 	viper.AutomaticEnv() // read in environment variables that match
 
 	// If a config file is found, read it in.
 	if err := viper.ReadInConfig(); err == nil {
 		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	}
+
+	if cfgFile == "" {
+		projectConfig, err := findProjectConfig()
+		cobra.CheckErr(err)
+		if projectConfig != "" && projectConfig != viper.ConfigFileUsed() {
+			settings, err := readProjectConfig(projectConfig)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failure reading config file %s: %v\n", projectConfig, err)
+				os.Exit(ErrorCodeInput)
+			}
+			cobra.CheckErr(viper.MergeConfigMap(settings))
+			fmt.Fprintln(os.Stderr, "Using config file:", projectConfig)
+		}
+	}
+
+	if profile := viper.GetString("profile"); profile != "" {
+		if err := useProfile(profile); err != nil {
+			fmt.Fprintf(os.Stderr, "Failure selecting profile: %v\n", err)
+			os.Exit(ErrorCodeInput)
+		}
+	}
 }
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/Comcast/Buildenv-Tool/reader"
+)
+
+// A signal during the read cleans up without racing the files being written.
+// Run with -race.
+func TestExitOnSignal_DuringRead(t *testing.T) {
+	config := &strings.Builder{}
+	config.WriteString("files:\n")
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(config, "  FILE_%d: \"contents %d\"\n", i, i)
+	}
+	path := filepath.Join(t.TempDir(), "variables.yml")
+	if err := os.WriteFile(path, []byte(config.String()), 0600); err != nil {
+		t.Fatal(err)
+	}
+	data, err := reader.LoadVariables(path)
+	if err != nil {
+		t.Fatalf("LoadVariables() error = %v", err)
+	}
+
+	rdr, _ := reader.NewReader(reader.WithSkipVault(true))
+	signals := make(chan os.Signal, 1)
+	exited := make(chan int, 1)
+	go exitOnSignal(signals, func(code int) {
+		rdr.Cleanup()
+		exited <- code
+	})
+	signals <- syscall.SIGINT
+
+	out, err := rdr.ReadScopes(context.Background(), data, reader.Selection{})
+	if code := <-exited; code != 130 {
+		t.Errorf("exit code = %d, want 130", code)
+	}
+	if err != nil && !errors.Is(err, reader.ErrCleanedUp) {
+		t.Fatalf("Reader.ReadScopes() error = %v, want %v", err, reader.ErrCleanedUp)
+	}
+	for _, o := range out {
+		if o.Key == "" {
+			continue
+		}
+		if _, err := os.Stat(o.Value); !os.IsNotExist(err) {
+			t.Errorf("%s was left at %s after the cleanup", o.Key, o.Value)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+/*
+Copyright © 2023 Comcast Cable Communications Management, LLC
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Comcast/Buildenv-Tool/reader"
+	"github.com/spf13/cobra"
+)
+
+// validateCmd checks variables files without reading any values
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check variables files for problems",
+	Long: `Check variables files, and the files they include, for unknown fields,
+values of the wrong type and invalid variable names. Every problem is
+reported with its file, line and column.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		variablesFiles, _ := cmd.Flags().GetStringArray("variables_file")
+		inputFormat, _ := cmd.Flags().GetString("input-format")
+		checkInputFormat(inputFormat)
+
+		problems := []reader.Problem{}
+		for _, file := range variablesFiles {
+			problems = append(problems, reader.ValidateFileFormat(file, inputFormat)...)
+		}
+		for _, problem := range problems {
+			fmt.Println(problem)
+		}
+		if len(problems) > 0 {
+			os.Exit(ErrorCodeYaml)
+		}
+
+		// Includes and extends are only checked once the files are merged
+		if err := reader.ValidateMergedFormat(inputFormat, variablesFiles...); err != nil {
+			fmt.Println(err)
+			os.Exit(ErrorCodeYaml)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+
+	validateCmd.Flags().StringArrayP("variables_file", "f", []string{"variables.yml"}, "Variables Source file (YAML, JSON, TOML or HCL), repeatable. A trailing ? marks a file as optional")
+	validateCmd.Flags().String("input-format", "", "Format of the variables files: yaml, json, toml or hcl (default is from the file extension, or yaml)")
+}
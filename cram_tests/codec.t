@@ -8,8 +8,9 @@ Make env vars
   $ be -v -f /dev/null -u INP -x > codec_test.blob
   $ export BLOB=`cat codec_test.blob`
   $ echo "$BLOB"
-  eyJWQVIxIjoiVkFMMSIsIlZBUjIiOiJWQUwyIn0=
+  eyJURVNUIjoibm8gc2VjcmV0cyIsIlZBUjEiOiJWQUwxIiwiVkFSMiI6IlZBTDIifQ==
   $ be -v -f /dev/null -u BLOB
-  export VAR1="VAL1"
-  export VAR2="VAL2"
+  export TEST='no secrets'
+  export VAR1='VAL1'
+  export VAR2='VAL2'
 
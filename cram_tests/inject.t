@@ -6,9 +6,21 @@ Try injecting quote
 
   $ echo '{"vars":{"Q": "\"; echo bad \""}}' > test.yml
   $ be -f test.yml
-  export Q="\"; echo bad \""
+  export TEST='no secrets'
+  export Q='"; echo bad "'
+
+Try injecting a command substitution
+
+  $ echo '{"vars":{"C": "$(echo bad) `echo bad` $HOME '"'"'x'"'"'"}}' > test3.yml
+  $ be -f test3.yml
+  export TEST='no secrets'
+  export C='$(echo bad) `echo bad` $HOME '\''x'\'''
+  $ eval "$(be -f test3.yml)" && printf '%s\n' "$C"
+  $(echo bad) `echo bad` $HOME 'x'
 
 Bad keys
 
   $ echo '{"vars":{"export hi=there; dosomethingevil && ": "hi"}}' > test2.yml
   $ be -f test2.yml
+  Failure loading variables: test2.yml:1:10: invalid variable name "export hi=there; dosomethingevil && ": names must start with a letter or _ and contain only letters, numbers and _ (no-eol)
+  [5]
@@ -0,0 +1,22 @@
+Setup
+
+  $ . "$TESTDIR"/setup.sh
+
+Repeated -f files are merged in order, later files winning. Before, only the
+last file was used, so the defaults from no_secrets.yml are kept here.
+
+  $ printf 'vars:\n  TEST: "overridden"\n  EXTRA: "extra"\n' > override.yml
+  $ be -c -f override.yml
+  # Global Variables
+  export TEST='overridden'
+  export EXTRA='extra'
+
+  $ printf 'vars:\n  OTHER: "other"\n' > other.yml
+  $ be -f other.yml
+  export TEST='no secrets'
+  export OTHER='other'
+
+A trailing ? skips a file that doesn't exist
+
+  $ be -f 'missing.yml?'
+  export TEST='no secrets'
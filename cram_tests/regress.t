@@ -6,5 +6,5 @@ Regular run
 
   $ be -cf "$TESTDIR"/../no_secrets.yml
   # Global Variables
-  export TEST="no secrets"
+  export TEST='no secrets'
 
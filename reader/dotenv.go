@@ -0,0 +1,141 @@
+package reader
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EnvFiles is a list of dotenv files to read variables from
+type EnvFiles []string
+
+func (e EnvFiles) GetOutput() (OutputList, error) {
+	output := OutputList{}
+	for _, path := range e {
+		fileOut, err := ReadEnvFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for i := range fileOut {
+			fileOut[i].Origin = path
+		}
+		output = append(output, fileOut...)
+	}
+	return output, nil
+}
+
+// ReadEnvFile reads a dotenv file, keeping the order of its entries
+func ReadEnvFile(path string) (OutputList, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read env file %s: %w", path, err)
+	}
+	entries, err := ParseDotenv(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("env file %s: %w", path, err)
+	}
+	output := OutputList{}
+	for _, entry := range entries {
+		output = append(output, Output{
+			Key:     entry[0],
+			Value:   entry[1],
+			Comment: fmt.Sprintf("File: %s", path),
+		})
+	}
+	return output, nil
+}
+
+// ParseDotenv parses dotenv content into ordered key/value pairs. It supports
+// `export` prefixes, comments, single quoted (literal) values, double quoted
+// values with escapes, and quoted values spanning multiple lines.
+func ParseDotenv(data string) ([][2]string, error) {
+	entries := [][2]string{}
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	lineNum := 0
+	for len(data) > 0 {
+		var line string
+		lineNum++
+		line, data, _ = strings.Cut(data, "\n")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		startLine := lineNum
+
+		if rest, isExport := strings.CutPrefix(trimmed, "export"); isExport && (rest == "" || rest[0] == ' ' || rest[0] == '\t') {
+			trimmed = strings.TrimSpace(rest)
+		}
+		key, value, hasEquals := strings.Cut(trimmed, "=")
+		key = strings.TrimSpace(key)
+		if !hasEquals {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", startLine)
+		}
+		if !shellvar_regexp.MatchString(key) {
+			return nil, fmt.Errorf("line %d: invalid variable name %q: names must start with a letter or _ and contain only letters, numbers and _", startLine, key)
+		}
+		value = strings.TrimLeft(value, " \t")
+
+		if len(value) > 0 && (value[0] == '\'' || value[0] == '"') {
+			quote := value[0]
+			raw := value[1:]
+			end := closingQuote(raw, quote)
+			// Quoted values may continue onto the following lines
+			for end < 0 {
+				if len(data) == 0 {
+					return nil, fmt.Errorf("line %d: unterminated quoted value for %s", startLine, key)
+				}
+				var next string
+				lineNum++
+				next, data, _ = strings.Cut(data, "\n")
+				raw += "\n" + next
+				end = closingQuote(raw, quote)
+			}
+			trailing := strings.TrimSpace(raw[end+1:])
+			if trailing != "" && !strings.HasPrefix(trailing, "#") {
+				return nil, fmt.Errorf("line %d: unexpected characters after quoted value for %s", lineNum, key)
+			}
+			raw = raw[:end]
+			if quote == '"' {
+				raw = unescapeDotenv(raw)
+			}
+			entries = append(entries, [2]string{key, raw})
+			continue
+		}
+
+		// Unquoted values end at an inline comment
+		if idx := strings.Index(value, " #"); idx >= 0 {
+			value = value[:idx]
+		} else if idx := strings.Index(value, "\t#"); idx >= 0 {
+			value = value[:idx]
+		}
+		entries = append(entries, [2]string{key, strings.TrimSpace(value)})
+	}
+	return entries, nil
+}
+
+// closingQuote finds the closing quote, skipping escaped double quotes
+func closingQuote(s string, quote byte) int {
+	for i := 0; i < len(s); i++ {
+		if quote == '"' && s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] == quote {
+			return i
+		}
+	}
+	return -1
+}
+
+var dotenvEscapes = strings.NewReplacer(
+	`\n`, "\n",
+	`\r`, "\r",
+	`\t`, "\t",
+	`\"`, `"`,
+	`\$`, `$`,
+	`\\`, `\`,
+)
+
+func unescapeDotenv(s string) string {
+	return dotenvEscapes.Replace(s)
+}
@@ -0,0 +1,156 @@
+package reader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    [][2]string
+		wantErr bool
+	}{
+		{
+			name: "Plain",
+			data: "A=1\nB = two\n",
+			want: [][2]string{{"A", "1"}, {"B", "two"}},
+		},
+		{
+			name: "Comments And Blank Lines",
+			data: "# heading\n\nA=1 # trailing\n  # indented\nB=a#b\n",
+			want: [][2]string{{"A", "1"}, {"B", "a#b"}},
+		},
+		{
+			name: "Export Prefix",
+			data: "export A=1\nexport\tB=2\nexported=3\n",
+			want: [][2]string{{"A", "1"}, {"B", "2"}, {"exported", "3"}},
+		},
+		{
+			name: "Single Quotes Are Literal",
+			data: `A='$HOME \n "x"' # comment`,
+			want: [][2]string{{"A", `$HOME \n "x"`}},
+		},
+		{
+			name: "Double Quote Escapes",
+			data: `A="line1\nline2 \"quoted\" \$HOME \\"`,
+			want: [][2]string{{"A", "line1\nline2 \"quoted\" $HOME \\"}},
+		},
+		{
+			name: "Multi-line Values",
+			data: "KEY=\"-----BEGIN KEY-----\nabc\n-----END KEY-----\"\nNEXT='a\nb'\nLAST=1",
+			want: [][2]string{{"KEY", "-----BEGIN KEY-----\nabc\n-----END KEY-----"}, {"NEXT", "a\nb"}, {"LAST", "1"}},
+		},
+		{
+			name: "Empty Values",
+			data: "A=\nB=\"\"\n",
+			want: [][2]string{{"A", ""}, {"B", ""}},
+		},
+		{
+			name: "Windows Line Endings",
+			data: "A=1\r\nB=2\r\n",
+			want: [][2]string{{"A", "1"}, {"B", "2"}},
+		},
+		{
+			name:    "Unterminated Quote",
+			data:    "A=\"never ends\nB=2\n",
+			wantErr: true,
+		},
+		{
+			name:    "Missing Equals",
+			data:    "JUSTAKEY\n",
+			wantErr: true,
+		},
+		{
+			name:    "Bad Key",
+			data:    "1BAD=x\n",
+			wantErr: true,
+		},
+		{
+			name:    "Dotted Key",
+			data:    "A=1\napp.name=x\n",
+			wantErr: true,
+		},
+		{
+			name:    "Junk After Quote",
+			data:    "A=\"x\" y\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDotenv(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseDotenv() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseDotenv() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvFiles_Read(t *testing.T) {
+	dir := t.TempDir()
+	globalFile := filepath.Join(dir, "global.env")
+	envFile := filepath.Join(dir, "dev.env")
+	os.WriteFile(globalFile, []byte("export FROM_FILE=global\n"), 0600)
+	os.WriteFile(envFile, []byte("DEV_FILE=\"dev\"\n"), 0600)
+
+	reader, _ := NewReader(WithSkipVault(true))
+	input := &Variables{
+		scope: scope{
+			Vars:     EnvVars{{"PLAIN", "plain"}},
+			EnvFiles: EnvFiles{globalFile},
+		},
+		Environments: map[string]Environment{
+			"dev": {
+				scope: scope{
+					EnvFiles: EnvFiles{envFile},
+				},
+			},
+		},
+	}
+	want := OutputList{
+		{Comment: "Global Variables"},
+		{Key: "PLAIN", Value: "plain"},
+		{Key: "FROM_FILE", Value: "global", Comment: "File: " + globalFile},
+		{Comment: "Environment: dev"},
+		{Key: "DEV_FILE", Value: "dev", Comment: "File: " + envFile},
+	}
+	got, err := reader.Read(context.Background(), input, "dev", "")
+	if err != nil {
+		t.Fatalf("Reader.Read() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Reader.Read() = %v, want %v", got, want)
+	}
+
+	input.EnvFiles = EnvFiles{filepath.Join(dir, "missing.env")}
+	if _, err := reader.Read(context.Background(), input, "dev", ""); err == nil {
+		t.Errorf("Reader.Read() with a missing env file should fail")
+	}
+}
+
+func TestLoadVariables_EnvFilesRelative(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config/variables.yml":       "include: [shared/included.yml]\nenv_files: [app.env]\nenvironments:\n  dev:\n    env_files: [/abs/dev.env]\n",
+		"config/shared/included.yml": "env_files: [../shared.env]\n",
+	})
+	got, err := LoadVariables(filepath.Join(dir, "config", "variables.yml"))
+	if err != nil {
+		t.Fatalf("LoadVariables() error = %v", err)
+	}
+	want := EnvFiles{filepath.Join(dir, "config", "shared.env"), filepath.Join(dir, "config", "app.env")}
+	if !reflect.DeepEqual(got.EnvFiles, want) {
+		t.Errorf("EnvFiles = %v, want %v", got.EnvFiles, want)
+	}
+	if want := (EnvFiles{"/abs/dev.env"}); !reflect.DeepEqual(got.Environments["dev"].EnvFiles, want) {
+		t.Errorf("dev EnvFiles = %v, want %v", got.Environments["dev"].EnvFiles, want)
+	}
+}
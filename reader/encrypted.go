@@ -0,0 +1,194 @@
+package reader
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// EncryptedTag marks a value in vars as age encrypted
+	EncryptedTag = "!encrypted"
+	// AgeIdentityFileEnv names the environment variable holding the path to an age identity file
+	AgeIdentityFileEnv = "BUILDENV_AGE_IDENTITY_FILE"
+	// AgePassphraseEnv names the environment variable holding an age passphrase
+	AgePassphraseEnv = "BUILDENV_AGE_PASSPHRASE"
+)
+
+// EncryptedVars are age ciphertext taken from `!encrypted` values in vars, in
+// the order they were declared
+type EncryptedVars []Var
+
+func (e *EncryptedVars) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: vars must be a mapping", node.Line)
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if value.Tag != EncryptedTag {
+			continue
+		}
+		*e = append(*e, Var{Name: key.Value, Value: strings.TrimSpace(value.Value)})
+	}
+	return nil
+}
+
+func (e EncryptedVars) MarshalJSON() ([]byte, error) {
+	return marshalVars(e)
+}
+
+func (e EncryptedVars) GetOutput(r *Reader) (OutputList, error) {
+	output := OutputList{}
+	if len(e) == 0 {
+		return output, nil
+	}
+
+	// Load the identities if necessary
+	if r.identities == nil {
+		err := r.InitAge()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, v := range e {
+		val, err := DecryptValue(v.Value, r.identities...)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decrypt %s: %w", v.Name, err)
+		}
+		output = append(output, Output{
+			Key:     v.Name,
+			Value:   val,
+			Comment: "Encrypted",
+			Secret:  true,
+		})
+	}
+	return output, nil
+}
+
+// InitAge loads the age identities used to decrypt `!encrypted` values from
+// the identity file and/or passphrase named in the environment.
+func (r *Reader) InitAge() error {
+	identities := []age.Identity{}
+
+	if identityFile, isSet := os.LookupEnv(AgeIdentityFileEnv); isSet && identityFile != "" {
+		f, err := os.Open(identityFile)
+		if err != nil {
+			return fmt.Errorf("unable to open age identity file: %w", err)
+		}
+		defer f.Close()
+		fileIdentities, err := age.ParseIdentities(f)
+		if err != nil {
+			return fmt.Errorf("unable to parse age identity file %s: %w", identityFile, err)
+		}
+		identities = append(identities, fileIdentities...)
+	}
+
+	if passphrase, isSet := os.LookupEnv(AgePassphraseEnv); isSet && passphrase != "" {
+		identity, err := age.NewScryptIdentity(passphrase)
+		if err != nil {
+			return fmt.Errorf("unable to use age passphrase: %w", err)
+		}
+		identities = append(identities, identity)
+	}
+
+	if len(identities) == 0 {
+		return fmt.Errorf("encrypted values found but neither %s nor %s is set", AgeIdentityFileEnv, AgePassphraseEnv)
+	}
+	r.identities = identities
+	return nil
+}
+
+// EncryptValue encrypts a plain value for the given recipients. The result is
+// base64 encoded so it fits on a single line after the `!encrypted` tag.
+func EncryptValue(plain string, recipients ...age.Recipient) (string, error) {
+	buf := &bytes.Buffer{}
+	w, err := age.Encrypt(buf, recipients...)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.WriteString(w, plain); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// DecryptValue decrypts a value produced by EncryptValue. ASCII armored age
+// ciphertext is accepted as well.
+func DecryptValue(ciphertext string, identities ...age.Identity) (string, error) {
+	var src io.Reader
+	if strings.HasPrefix(ciphertext, armor.Header) {
+		src = armor.NewReader(strings.NewReader(ciphertext))
+	} else {
+		raw, err := base64.StdEncoding.DecodeString(ciphertext)
+		if err != nil {
+			return "", fmt.Errorf("ciphertext is neither armored nor base64: %w", err)
+		}
+		src = bytes.NewReader(raw)
+	}
+	plain, err := age.Decrypt(src, identities...)
+	if err != nil {
+		return "", err
+	}
+	out, err := io.ReadAll(plain)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// mappingValue finds the value node for a key in a mapping node
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// decodeEncrypted collects the `!encrypted` entries of a scope's vars
+func decodeEncrypted(node *yaml.Node, out *EncryptedVars) error {
+	vars := mappingValue(node, "vars")
+	if vars == nil {
+		return nil
+	}
+	return vars.Decode(out)
+}
+
+func (v *Variables) UnmarshalYAML(node *yaml.Node) error {
+	type plain Variables
+	if err := node.Decode((*plain)(v)); err != nil {
+		return err
+	}
+	return v.finishDecode(node)
+}
+
+func (e *Environment) UnmarshalYAML(node *yaml.Node) error {
+	type plain Environment
+	if err := node.Decode((*plain)(e)); err != nil {
+		return err
+	}
+	return e.finishDecode(node)
+}
+
+func (d *DC) UnmarshalYAML(node *yaml.Node) error {
+	type plain DC
+	if err := node.Decode((*plain)(d)); err != nil {
+		return err
+	}
+	return d.finishDecode(node)
+}
@@ -0,0 +1,206 @@
+package reader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"filippo.io/age"
+	"gopkg.in/yaml.v3"
+)
+
+func TestEnvVars_UnmarshalEncrypted(t *testing.T) {
+	in := `
+vars:
+  PLAIN: "plain"
+  SECRET: !encrypted c2VjcmV0
+environments:
+  dev:
+    vars:
+      DEV_SECRET: !encrypted ZGV2
+    dcs:
+      east:
+        vars:
+          DC: "east"
+`
+	var got Variables
+	if err := yaml.Unmarshal([]byte(in), &got); err != nil {
+		t.Fatalf("yaml.Unmarshal() error = %v", err)
+	}
+	if want := (EnvVars{{"PLAIN", "plain"}}); !reflect.DeepEqual(got.Vars, want) {
+		t.Errorf("Vars = %v, want %v", got.Vars, want)
+	}
+	if want := (EncryptedVars{{"SECRET", "c2VjcmV0"}}); !reflect.DeepEqual(got.Encrypted, want) {
+		t.Errorf("Encrypted = %v, want %v", got.Encrypted, want)
+	}
+	if want := (EncryptedVars{{"DEV_SECRET", "ZGV2"}}); !reflect.DeepEqual(got.Environments["dev"].Encrypted, want) {
+		t.Errorf("Environments[dev].Encrypted = %v, want %v", got.Environments["dev"].Encrypted, want)
+	}
+	if got.Environments["dev"].Dcs["east"].Encrypted != nil {
+		t.Errorf("Dcs[east].Encrypted = %v, want nil", got.Environments["dev"].Dcs["east"].Encrypted)
+	}
+}
+
+func TestEncryptedVars_GetOutput(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	identityFile := filepath.Join(t.TempDir(), "key.txt")
+	if err := os.WriteFile(identityFile, []byte(identity.String()+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	keyEncrypted, err := EncryptValue("from key", identity.Recipient())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	passRecipient, _ := age.NewScryptRecipient("hunter2")
+	passRecipient.SetWorkFactor(10)
+	passEncrypted, err := EncryptValue("from passphrase", passRecipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		identityFile string
+		passphrase   string
+		e            EncryptedVars
+		want         OutputList
+		wantErr      bool
+	}{
+		{
+			name:         "Identity File",
+			identityFile: identityFile,
+			e:            EncryptedVars{{"KEY", keyEncrypted}},
+			want: OutputList{
+				{Key: "KEY", Value: "from key", Comment: "Encrypted", Secret: true},
+			},
+		},
+		{
+			name:       "Passphrase",
+			passphrase: "hunter2",
+			e:          EncryptedVars{{"PASS", passEncrypted}},
+			want: OutputList{
+				{Key: "PASS", Value: "from passphrase", Comment: "Encrypted", Secret: true},
+			},
+		},
+		{
+			name:         "Both",
+			identityFile: identityFile,
+			passphrase:   "hunter2",
+			e:            EncryptedVars{{"PASS", passEncrypted}, {"KEY", keyEncrypted}},
+			want: OutputList{
+				{Key: "PASS", Value: "from passphrase", Comment: "Encrypted", Secret: true},
+				{Key: "KEY", Value: "from key", Comment: "Encrypted", Secret: true},
+			},
+		},
+		{
+			name:       "Wrong Passphrase",
+			passphrase: "wrong",
+			e:          EncryptedVars{{"PASS", passEncrypted}},
+			wantErr:    true,
+		},
+		{
+			name:    "No Identity",
+			e:       EncryptedVars{{"KEY", keyEncrypted}},
+			wantErr: true,
+		},
+		{
+			name: "Nothing Encrypted",
+			e:    EncryptedVars{},
+			want: OutputList{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(AgeIdentityFileEnv, tt.identityFile)
+			t.Setenv(AgePassphraseEnv, tt.passphrase)
+			r, _ := NewReader()
+			got, err := tt.e.GetOutput(r)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("EncryptedVars.GetOutput() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("EncryptedVars.GetOutput() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncrypted_SkipVault(t *testing.T) {
+	reader, _ := NewReader(WithSkipVault(true))
+	input := &Variables{
+		scope: scope{
+			Vars:      EnvVars{{"PLAIN", "plain"}},
+			Encrypted: EncryptedVars{{"SECRET", "not even decryptable"}},
+		},
+	}
+	want := OutputList{
+		{Comment: "Global Variables"},
+		{Key: "PLAIN", Value: "plain"},
+	}
+	got, err := reader.Read(context.Background(), input, "", "")
+	if err != nil {
+		t.Fatalf("Reader.Read() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Reader.Read() = %v, want %v", got, want)
+	}
+}
+
+func TestReader_ReadEncryptedInOrder(t *testing.T) {
+	recipient, _ := age.NewScryptRecipient("hunter2")
+	recipient.SetWorkFactor(10)
+	encrypted, err := EncryptValue("two", recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := `
+vars:
+  A: one
+  B: !encrypted ` + encrypted + `
+  C: three
+`
+	var input Variables
+	if err := yaml.Unmarshal([]byte(in), &input); err != nil {
+		t.Fatalf("yaml.Unmarshal() error = %v", err)
+	}
+	t.Setenv(AgeIdentityFileEnv, "")
+	t.Setenv(AgePassphraseEnv, "hunter2")
+	reader, _ := NewReader()
+	got, err := reader.Read(context.Background(), &input, "", "")
+	if err != nil {
+		t.Fatalf("Reader.Read() error = %v", err)
+	}
+	want := OutputList{
+		{Comment: "Global Variables"},
+		{Key: "A", Value: "one"},
+		{Key: "B", Value: "two", Comment: "Encrypted", Secret: true},
+		{Key: "C", Value: "three"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Reader.Read() = %v, want %v", got, want)
+	}
+}
+
+func TestOutputList_Redacted(t *testing.T) {
+	in := OutputList{
+		{Key: "PLAIN", Value: "plain"},
+		{Key: "SECRET", Value: "shh", Secret: true},
+	}
+	want := OutputList{
+		{Key: "PLAIN", Value: "plain"},
+		{Key: "SECRET", Value: RedactedValue, Secret: true},
+	}
+	if got := in.Redacted(); !reflect.DeepEqual(got, want) {
+		t.Errorf("OutputList.Redacted() = %v, want %v", got, want)
+	}
+	if in[1].Value != "shh" {
+		t.Errorf("OutputList.Redacted() modified the original list")
+	}
+}
@@ -0,0 +1,119 @@
+package reader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultExecTimeout limits how long an exec_vars command may run
+const DefaultExecTimeout = 30 * time.Second
+
+// ExecVar is a command whose trimmed standard output becomes a variable's
+// value. It can be written as just the command, or as a mapping with a
+// timeout and secret flag.
+type ExecVar struct {
+	Command string        `yaml:"command"`
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	Secret  bool          `yaml:"secret,omitempty"`
+}
+
+func (e *ExecVar) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		e.Command = node.Value
+		return nil
+	}
+	type plain ExecVar
+	return node.Decode((*plain)(e))
+}
+
+// ExecVars are the commands for variables, in the order they were declared
+type ExecVars []Named[ExecVar]
+
+func (e *ExecVars) UnmarshalYAML(node *yaml.Node) error {
+	vars, err := decodeVars[ExecVar](node, "exec_vars", nil, nil)
+	*e = vars
+	return err
+}
+
+func (e ExecVars) MarshalJSON() ([]byte, error) {
+	return marshalVars(e)
+}
+
+func (e ExecVars) GetOutput(ctx context.Context) (OutputList, error) {
+	// Run all of the commands at once
+	results := make(OutputList, len(e))
+	errs := make([]error, len(e))
+	var wg sync.WaitGroup
+	for i, v := range e {
+		wg.Add(1)
+		go func(i int, varName string, execVar ExecVar) {
+			defer wg.Done()
+			val, err := execVar.run(ctx)
+			if err != nil {
+				errs[i] = fmt.Errorf("error running command for %s '%s': %w", varName, execVar.Command, err)
+				return
+			}
+			results[i] = Output{
+				Key:     varName,
+				Value:   val,
+				Comment: fmt.Sprintf("Command: %s", execVar.Command),
+				Secret:  execVar.Secret,
+			}
+		}(i, v.Name, v.Value)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+func (e ExecVar) run(ctx context.Context) (string, error) {
+	if strings.TrimSpace(e.Command) == "" {
+		return "", errors.New("no command given")
+	}
+	timeout := e.Timeout
+	if timeout <= 0 {
+		timeout = DefaultExecTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := shellCommand(ctx, e.Command)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	// Don't wait on grandchildren holding the output open after a timeout
+	cmd.WaitDelay = time.Second
+	out, err := cmd.Output()
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("timed out after %s", timeout)
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// shellCommand runs a command with the user's shell, falling back to bash
+func shellCommand(ctx context.Context, shell_cmd string) *exec.Cmd {
+	shell, shell_isset := os.LookupEnv("SHELL")
+	if shell_isset {
+		return exec.CommandContext(ctx, shell, "-c", shell_cmd)
+	}
+	return exec.CommandContext(ctx, "/usr/bin/env", "bash", "-c", shell_cmd)
+}
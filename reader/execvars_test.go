@@ -0,0 +1,128 @@
+package reader
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestExecVar_UnmarshalYAML(t *testing.T) {
+	in := `
+SHA: git rev-parse HEAD
+TOKEN:
+  command: print-token
+  timeout: 5s
+  secret: true
+`
+	var got ExecVars
+	if err := yaml.Unmarshal([]byte(in), &got); err != nil {
+		t.Fatalf("yaml.Unmarshal() error = %v", err)
+	}
+	want := ExecVars{
+		{"SHA", ExecVar{Command: "git rev-parse HEAD"}},
+		{"TOKEN", ExecVar{Command: "print-token", Timeout: 5 * time.Second, Secret: true}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("yaml.Unmarshal() = %+v, want %+v", got, want)
+	}
+}
+
+func TestExecVars_GetOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		e       ExecVars
+		want    OutputList
+		wantErr string
+	}{
+		{
+			name: "Trimmed Output In Declared Order",
+			e: ExecVars{
+				{"B", ExecVar{Command: "printf '  two\\n\\n'"}},
+				{"A", ExecVar{Command: "echo one", Secret: true}},
+			},
+			want: OutputList{
+				{Key: "B", Value: "two", Comment: "Command: printf '  two\\n\\n'"},
+				{Key: "A", Value: "one", Comment: "Command: echo one", Secret: true},
+			},
+		},
+		{
+			name: "Failure",
+			e: ExecVars{
+				{"OK", ExecVar{Command: "echo fine"}},
+				{"FAIL", ExecVar{Command: "echo broken >&2; exit 3"}},
+			},
+			wantErr: "error running command for FAIL 'echo broken >&2; exit 3': exit status 3: broken",
+		},
+		{
+			name: "Timeout",
+			e: ExecVars{
+				{"SLOW", ExecVar{Command: "sleep 5", Timeout: 100 * time.Millisecond}},
+			},
+			wantErr: "error running command for SLOW 'sleep 5': timed out after 100ms",
+		},
+		{
+			name: "Empty Command",
+			e: ExecVars{
+				{"EMPTY", ExecVar{}},
+			},
+			wantErr: "error running command for EMPTY '': no command given",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.e.GetOutput(context.Background())
+			if err != nil {
+				if tt.wantErr == "" || err.Error() != tt.wantErr {
+					t.Errorf("ExecVars.GetOutput() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Errorf("ExecVars.GetOutput() error = nil, wantErr %v", tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExecVars.GetOutput() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecVars_Parallel(t *testing.T) {
+	e := ExecVars{
+		{"ONE", ExecVar{Command: "sleep 0.4; echo 1"}},
+		{"TWO", ExecVar{Command: "sleep 0.4; echo 2"}},
+		{"THREE", ExecVar{Command: "sleep 0.4; echo 3"}},
+	}
+	start := time.Now()
+	got, err := e.GetOutput(context.Background())
+	if err != nil {
+		t.Fatalf("ExecVars.GetOutput() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("ExecVars.GetOutput() took %s, commands should run in parallel", elapsed)
+	}
+	values := []string{}
+	for _, out := range got {
+		values = append(values, out.Key+"="+out.Value)
+	}
+	if strings.Join(values, ",") != "ONE=1,TWO=2,THREE=3" {
+		t.Errorf("ExecVars.GetOutput() = %v", values)
+	}
+}
+
+func TestExecVars_ReadError(t *testing.T) {
+	reader, _ := NewReader(WithSkipVault(true))
+	input := &Variables{
+		scope: scope{
+			ExecVars: ExecVars{{"FAIL", ExecVar{Command: "exit 1"}}},
+		},
+	}
+	_, err := reader.Read(context.Background(), input, "", "")
+	if err == nil || err.Error() != "exec error: error running command for FAIL 'exit 1': exit status 1" {
+		t.Errorf("Reader.Read() error = %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package reader
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Definition is one place a variable was set
+type Definition struct {
+	Origin string
+	Scope  string
+	// Unset is true where the variable was removed rather than set
+	Unset bool
+}
+
+func (d Definition) String() string {
+	origin := d.Origin
+	if origin == "" {
+		origin = "unknown file"
+	}
+	if d.Unset {
+		origin = "unset in " + origin
+	}
+	if d.Scope == "" {
+		return origin
+	}
+	return fmt.Sprintf("%s (%s)", origin, d.Scope)
+}
+
+// Explanation traces where the final value of a variable came from
+type Explanation struct {
+	Key        string
+	Definition Definition
+	// Overrides are the earlier definitions it replaced, first to last
+	Overrides []Definition
+}
+
+func (e Explanation) String() string {
+	explained := fmt.Sprintf("%s: %s", e.Key, e.Definition)
+	if len(e.Overrides) > 0 {
+		overrides := []string{}
+		for _, def := range e.Overrides {
+			overrides = append(overrides, def.String())
+		}
+		explained += fmt.Sprintf(", overrides %s", strings.Join(overrides, ", "))
+	}
+	return explained
+}
+
+// Explain traces each variable in the list, sorted by name. The scope of a
+// definition is the comment heading the section it appears in, e.g.
+// "Environment: stage".
+func (o OutputList) Explain() []Explanation {
+	definitions := map[string][]Definition{}
+	section := ""
+	for _, out := range o {
+		if out.Key == "" {
+			section = out.Comment
+			continue
+		}
+		definitions[out.Key] = append(definitions[out.Key], Definition{
+			Origin: out.Origin,
+			Scope:  section,
+			Unset:  out.Unset,
+		})
+	}
+
+	explanations := []Explanation{}
+	for _, key := range sortedKeys(definitions) {
+		defs := definitions[key]
+		last := len(defs) - 1
+		explanation := Explanation{
+			Key:        key,
+			Definition: defs[last],
+		}
+		if last > 0 {
+			explanation.Overrides = defs[:last]
+		}
+		explanations = append(explanations, explanation)
+	}
+	return explanations
+}
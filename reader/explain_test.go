@@ -0,0 +1,31 @@
+package reader
+
+import "testing"
+
+func TestExplanation_String(t *testing.T) {
+	out := OutputList{
+		{Comment: "Global Variables"},
+		{Key: "HOST", Value: "a", Origin: "base.yml"},
+		{Key: "PROXY", Value: "http://proxy", Origin: "base.yml"},
+		{Comment: "Environment: prod"},
+		{Key: "HOST", Value: "b", Origin: "variables.yml"},
+		{Key: "PROXY", Unset: true, Origin: "variables.yml"},
+		{Comment: "Command Line"},
+		{Key: "HOST", Value: "c", Origin: "local.env"},
+		{Key: "LITERAL", Value: "d"},
+	}
+	want := []string{
+		"HOST: local.env (Command Line), overrides base.yml (Global Variables), variables.yml (Environment: prod)",
+		"LITERAL: unknown file (Command Line)",
+		"PROXY: unset in variables.yml (Environment: prod), overrides base.yml (Global Variables)",
+	}
+	got := out.Explain()
+	if len(got) != len(want) {
+		t.Fatalf("OutputList.Explain() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i].String() != want[i] {
+			t.Errorf("Explanation.String() = %q, want %q", got[i].String(), want[i])
+		}
+	}
+}
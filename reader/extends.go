@@ -0,0 +1,222 @@
+package reader
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Extends names the environments (or datacenters) a scope inherits from. It
+// can be written as a single name or a list, where later entries win.
+type Extends []string
+
+func (e *Extends) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*e = Extends{node.Value}
+		return nil
+	}
+	var names []string
+	if err := node.Decode(&names); err != nil {
+		return err
+	}
+	*e = names
+	return nil
+}
+
+// resolveExtends replaces every environment and datacenter that extends
+// others with the merged result, recording which ancestor supplied each
+// inherited value. Each environment is resolved before its datacenters, so
+// they can extend the datacenters it inherits.
+func (v *Variables) resolveExtends() error {
+	if v.Inherited == nil {
+		v.Inherited = map[string]string{}
+	}
+
+	resolved := map[string]bool{}
+	for _, envName := range sortedKeys(v.Environments) {
+		if err := v.resolveEnvironment(envName, resolved, []string{}); err != nil {
+			return err
+		}
+	}
+	if len(v.Inherited) == 0 {
+		v.Inherited = nil
+	}
+	return nil
+}
+
+// resolveEnvironment resolves an environment, then its datacenters. The
+// stack holds the environments and datacenters being resolved, as
+// datacenters can extend those of other environments.
+func (v *Variables) resolveEnvironment(name string, resolved map[string]bool, stack []string) error {
+	if resolved[name] {
+		return nil
+	}
+	if i := slices.Index(stack, name); i >= 0 {
+		return fmt.Errorf("environment extends cycle: %s -> %s", strings.Join(stack[i:], " -> "), name)
+	}
+	stack = append(stack, name)
+
+	env := v.Environments[name]
+	path := fmt.Sprintf("environments.%s", name)
+	own := env.keys()
+	merged := Environment{}
+	for _, parentName := range env.Extends {
+		if _, found := v.Environments[parentName]; !found {
+			return fmt.Errorf("environment %s extends unknown environment %s", name, parentName)
+		}
+		if err := v.resolveEnvironment(parentName, resolved, stack); err != nil {
+			return err
+		}
+		parent := v.Environments[parentName]
+		v.inherit(path, fmt.Sprintf("environments.%s", parentName), parentName, parent.keys(), own)
+		merged = mergeEnvironments(merged, parent)
+	}
+	if len(env.Extends) > 0 {
+		merged = mergeEnvironments(merged, env)
+		merged.Extends = nil
+		v.Environments[name] = merged
+	}
+
+	// Datacenters can extend siblings by name, or others with "env/dc"
+	for _, dcName := range sortedKeys(v.Environments[name].Dcs) {
+		if err := v.resolveDC(name, dcName, resolved, stack); err != nil {
+			return err
+		}
+	}
+	resolved[name] = true
+	return nil
+}
+
+func (v *Variables) resolveDC(envName string, dcName string, resolved map[string]bool, stack []string) error {
+	ref := envName + "/" + dcName
+	if resolved[ref] {
+		return nil
+	}
+	if i := slices.Index(stack, ref); i >= 0 {
+		return fmt.Errorf("datacenter extends cycle: %s -> %s", strings.Join(stack[i:], " -> "), ref)
+	}
+	stack = append(stack, ref)
+
+	dc := v.Environments[envName].Dcs[dcName]
+	path := fmt.Sprintf("environments.%s.dcs.%s", envName, dcName)
+	own := dc.keys()
+	merged := scope{}
+	var mergedScopes Scopes
+	for _, parentRef := range dc.Extends {
+		parentEnv, parentDC, isQualified := strings.Cut(parentRef, "/")
+		if !isQualified {
+			parentEnv, parentDC = envName, parentRef
+		}
+		// Another environment is resolved first, for the datacenters it inherits
+		if _, found := v.Environments[parentEnv]; found && parentEnv != envName {
+			if err := v.resolveEnvironment(parentEnv, resolved, stack); err != nil {
+				return err
+			}
+		}
+		if _, found := v.Environments[parentEnv].Dcs[parentDC]; !found {
+			return fmt.Errorf("datacenter %s extends unknown datacenter %s", ref, parentRef)
+		}
+		if err := v.resolveDC(parentEnv, parentDC, resolved, stack); err != nil {
+			return err
+		}
+		parent := v.Environments[parentEnv].Dcs[parentDC]
+		v.inherit(path, fmt.Sprintf("environments.%s.dcs.%s", parentEnv, parentDC), parentRef, parent.keys(), own)
+		merged = mergeScopes(merged, parent.scope)
+		mergedScopes = mergeScopeTrees(mergedScopes, parent.Scopes)
+	}
+	if len(dc.Extends) > 0 {
+		merged = mergeScopes(merged, dc.scope)
+		v.Environments[envName].Dcs[dcName] = DC{
+			scope:  merged,
+			Scopes: mergeScopeTrees(mergedScopes, dc.Scopes),
+		}
+	}
+	resolved[ref] = true
+	return nil
+}
+
+// inherit marks the values under path that come from a parent, keeping the
+// original ancestor for values the parent inherited itself. Values the scope
+// sets itself (own) aren't inherited.
+func (v *Variables) inherit(path string, parentPath string, parentName string, keys []string, own []string) {
+	for _, key := range keys {
+		if slices.Contains(own, key) {
+			continue
+		}
+		ancestor := parentName
+		if parentAncestor, found := v.Inherited[parentPath+"."+key]; found {
+			ancestor = parentAncestor
+		}
+		v.Inherited[path+"."+key] = ancestor
+		if origin, found := v.Origins[parentPath+"."+key]; found {
+			if v.Origins == nil {
+				v.Origins = map[string]string{}
+			}
+			v.Origins[path+"."+key] = origin
+		}
+	}
+}
+
+// keys lists the values in an environment, relative to it, e.g. "vars.FOO"
+// and "dcs.east.vars.BAR"
+func (e Environment) keys() []string {
+	keyMap := map[string]string{}
+	e.scope.addOrigins(keyMap, "", "")
+	e.Scopes.addOrigins(keyMap, "", "")
+	for dcName, dc := range e.Dcs {
+		dcPath := fmt.Sprintf("dcs.%s", dcName)
+		dc.scope.addOrigins(keyMap, dcPath, "")
+		dc.Scopes.addOrigins(keyMap, dcPath, "")
+	}
+	return sortedKeys(keyMap)
+}
+
+// keys lists the values in a datacenter, including its scopes
+func (d DC) keys() []string {
+	keyMap := map[string]string{}
+	d.scope.addOrigins(keyMap, "", "")
+	d.Scopes.addOrigins(keyMap, "", "")
+	return sortedKeys(keyMap)
+}
+
+// annotate records where each value came from: the file that set it, and the
+// ancestor that supplied it if it was inherited
+func (s sourcedScope) annotate(output OutputList, block string) OutputList {
+	for i, out := range output {
+		output[i] = s.annotateOutput(out, block+"."+out.Key)
+	}
+	return output
+}
+
+// annotateOutput records where the value at key came from
+func (s sourcedScope) annotateOutput(out Output, key string) Output {
+	if s.path != "" {
+		key = s.path + "." + key
+	}
+	out.Origin = s.file
+	if origin, found := s.origins[key]; found {
+		out.Origin = origin
+	}
+	if ancestor, found := s.inherited[key]; found {
+		out.Comment = joinComment(out.Comment, fmt.Sprintf("Inherited from: %s", ancestor))
+	}
+	return out
+}
+
+func joinComment(comment string, extra string) string {
+	if comment == "" {
+		return extra
+	}
+	return comment + ", " + extra
+}
+
+func sortedKeys[M ~map[string]V, V any](m M) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
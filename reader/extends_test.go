@@ -0,0 +1,148 @@
+package reader
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadVariables_Extends(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"variables.yml": `
+environments:
+  base:
+    vars:
+      LOG_LEVEL: "info"
+      REPLICAS: "1"
+  stage:
+    extends: base
+    vars:
+      ENVIRONMENT: "stage"
+      REPLICAS: "2"
+    dcs:
+      east:
+        vars:
+          DC: "east"
+          REGION: "us-east-1"
+      west:
+        extends: east
+        vars:
+          DC: "west"
+  stage-east:
+    extends: stage
+    vars:
+      ENVIRONMENT: "stage-east"
+    dcs:
+      north:
+        extends: east
+        vars:
+          DC: "north"
+  layered:
+    extends: [stage, base]
+    vars:
+      EXTRA: "yes"
+`,
+	})
+
+	file := filepath.Join(dir, "variables.yml")
+	got, err := LoadVariables(file)
+	if err != nil {
+		t.Fatalf("LoadVariables() error = %v", err)
+	}
+
+	tests := []struct {
+		env  string
+		dc   string
+		want OutputList
+	}{
+		{
+			env: "stage-east",
+			dc:  "west",
+			want: OutputList{
+				{Comment: "Global Variables"},
+				{Comment: "Environment: stage-east"},
+				{Key: "LOG_LEVEL", Value: "info", Comment: "Inherited from: base"},
+				{Key: "REPLICAS", Value: "2", Comment: "Inherited from: stage"},
+				{Key: "ENVIRONMENT", Value: "stage-east"},
+				{Comment: "Datacenter: west"},
+				{Key: "DC", Value: "west", Comment: "Inherited from: stage"},
+				{Key: "REGION", Value: "us-east-1", Comment: "Inherited from: east"},
+			},
+		},
+		{
+			// east is only inherited from stage
+			env: "stage-east",
+			dc:  "north",
+			want: OutputList{
+				{Comment: "Global Variables"},
+				{Comment: "Environment: stage-east"},
+				{Key: "LOG_LEVEL", Value: "info", Comment: "Inherited from: base"},
+				{Key: "REPLICAS", Value: "2", Comment: "Inherited from: stage"},
+				{Key: "ENVIRONMENT", Value: "stage-east"},
+				{Comment: "Datacenter: north"},
+				{Key: "DC", Value: "north"},
+				{Key: "REGION", Value: "us-east-1", Comment: "Inherited from: stage"},
+			},
+		},
+		{
+			env: "layered",
+			want: OutputList{
+				{Comment: "Global Variables"},
+				{Comment: "Environment: layered"},
+				{Key: "LOG_LEVEL", Value: "info", Comment: "Inherited from: base"},
+				{Key: "REPLICAS", Value: "1", Comment: "Inherited from: base"},
+				{Key: "ENVIRONMENT", Value: "stage", Comment: "Inherited from: stage"},
+				{Key: "EXTRA", Value: "yes"},
+			},
+		},
+	}
+	reader, _ := NewReader(WithSkipVault(true))
+	for _, tt := range tests {
+		t.Run(tt.env+"/"+tt.dc, func(t *testing.T) {
+			out, err := reader.Read(context.Background(), got, tt.env, tt.dc)
+			if err != nil {
+				t.Fatalf("Reader.Read() error = %v", err)
+			}
+			for i := range tt.want {
+				if tt.want[i].Key != "" {
+					tt.want[i].Origin = file
+				}
+			}
+			if !reflect.DeepEqual(out, tt.want) {
+				t.Errorf("Reader.Read() = %v, want %v", out, tt.want)
+			}
+		})
+	}
+
+	if origin := got.Origins["environments.stage-east.vars.LOG_LEVEL"]; origin != file {
+		t.Errorf("Origins of inherited value = %v", origin)
+	}
+}
+
+func TestLoadVariables_ExtendsErrors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"cycle.yml":      "environments:\n  a:\n    extends: b\n  b:\n    extends: a\n",
+		"unknown.yml":    "environments:\n  a:\n    extends: nope\n",
+		"dc-cycle.yml":   "environments:\n  a:\n    dcs:\n      x:\n        extends: y\n      y:\n        extends: x\n",
+		"dc-unknown.yml": "environments:\n  a:\n    dcs:\n      x:\n        extends: b/y\n",
+	})
+	tests := []struct {
+		file    string
+		wantErr string
+	}{
+		{file: "cycle.yml", wantErr: "environment extends cycle: a -> b -> a"},
+		{file: "unknown.yml", wantErr: "environment a extends unknown environment nope"},
+		{file: "dc-cycle.yml", wantErr: "datacenter extends cycle: a/x -> a/y -> a/x"},
+		{file: "dc-unknown.yml", wantErr: "datacenter a/x extends unknown datacenter b/y"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			_, err := LoadVariables(filepath.Join(dir, tt.file))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadVariables() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
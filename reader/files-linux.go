@@ -0,0 +1,12 @@
+//go:build linux
+// +build linux
+
+package reader
+
+import "os"
+
+// tempRoots lists where private directories for files can go, best first.
+// /dev/shm is a tmpfs, so the files there are never written to disk.
+func tempRoots() []string {
+	return []string{"/dev/shm", os.TempDir()}
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package reader
+
+import "os"
+
+// tempRoots lists where private directories for files can go, best first
+func tempRoots() []string {
+	return []string{os.TempDir()}
+}
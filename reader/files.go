@@ -0,0 +1,169 @@
+package reader
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileValue is a value written to a file, for tools that want a path rather
+// than the value itself. The value is either given in the file or read from
+// a Vault KV path and key, and can be base64 decoded before it's written. A
+// literal value can be written as just the value.
+type FileValue struct {
+	Value  string `yaml:"value,omitempty"`
+	Path   string `yaml:"path,omitempty"`
+	Key    string `yaml:"key,omitempty"`
+	Base64 bool   `yaml:"base64,omitempty"`
+	// Name is the name of the file, which defaults to the variable's
+	Name string `yaml:"name,omitempty"`
+}
+
+func (f *FileValue) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		f.Value = node.Value
+		return nil
+	}
+	type plain FileValue
+	return node.Decode((*plain)(f))
+}
+
+// Files are values written to files, in the order their variables were
+// declared; each variable is set to the path of its file
+type Files []Named[FileValue]
+
+func (f *Files) UnmarshalYAML(node *yaml.Node) error {
+	files, err := decodeVars[FileValue](node, "files", nil, nil)
+	*f = files
+	return err
+}
+
+func (f Files) MarshalJSON() ([]byte, error) {
+	return marshalVars(f)
+}
+
+// GetOutput writes each file and exports its path. Values from Vault are
+// skipped when skipping Vault.
+func (f Files) GetOutput(ctx context.Context, r *Reader) (OutputList, error) {
+	output := OutputList{}
+	for _, v := range f {
+		varName, file := v.Name, v.Value
+		if file.Path != "" && r.skipVault {
+			continue
+		}
+		data, comment, err := file.read(ctx, r)
+		if err != nil {
+			return nil, fmt.Errorf("file for %s: %w", varName, err)
+		}
+		name := file.Name
+		if name == "" {
+			name = varName
+		}
+		path, err := r.writeFile(name, data)
+		if err != nil {
+			return nil, fmt.Errorf("file for %s: %w", varName, err)
+		}
+		output = append(output, Output{
+			Key:     varName,
+			Value:   path,
+			Comment: comment,
+		})
+	}
+	return output, nil
+}
+
+// read gets the contents of the file
+func (f FileValue) read(ctx context.Context, r *Reader) ([]byte, string, error) {
+	value, comment := f.Value, "File"
+	if f.Path != "" {
+		key := f.Key
+		if key == "" {
+			key = "value"
+		}
+		data, err := r.readKV(ctx, f.Path, false)
+		if err != nil {
+			return nil, "", err
+		}
+		found, hasValue := data[key]
+		if !hasValue {
+			return nil, "", fmt.Errorf("key %s not found in path %s", key, f.Path)
+		}
+		value = secretValue(found, DefaultSeparator)
+		comment = fmt.Sprintf("File, Path: %s, Key: %s", f.Path, key)
+	}
+	if !f.Base64 {
+		return []byte(value), comment, nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, "", fmt.Errorf("decoding base64: %w", err)
+	}
+	return decoded, comment, nil
+}
+
+// writeFile writes data to a new file readable only by this user, in a
+// private temporary directory that Cleanup removes. Each file gets its own
+// directory so files for different scopes can share a name.
+func (r *Reader) writeFile(name string, data []byte) (string, error) {
+	if name != filepath.Base(name) || name == "." || name == ".." {
+		return "", fmt.Errorf("file name %q must not contain a directory", name)
+	}
+	// Cleanup can run on another goroutine, such as when interrupted
+	r.filesMu.Lock()
+	defer r.filesMu.Unlock()
+	if r.cleanedUp {
+		return "", ErrCleanedUp
+	}
+	if r.fileDir == "" {
+		dir, err := privateTempDir()
+		if err != nil {
+			return "", err
+		}
+		r.fileDir = dir
+	}
+	dir, err := os.MkdirTemp(r.fileDir, "")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// privateTempDir makes a directory only this user can use, in the first
+// place that allows it
+func privateTempDir() (string, error) {
+	var err error
+	for _, root := range tempRoots() {
+		var dir string
+		dir, err = os.MkdirTemp(root, "buildenv-")
+		if err == nil {
+			return dir, nil
+		}
+	}
+	return "", err
+}
+
+// ErrCleanedUp is returned for files written after Cleanup
+var ErrCleanedUp = errors.New("files were already cleaned up")
+
+// Cleanup removes the files written for `files` values. It's safe to call
+// while values are being read, which then can't write any more files.
+func (r *Reader) Cleanup() error {
+	r.filesMu.Lock()
+	defer r.filesMu.Unlock()
+	r.cleanedUp = true
+	if r.fileDir == "" {
+		return nil
+	}
+	err := os.RemoveAll(r.fileDir)
+	r.fileDir = ""
+	return err
+}
@@ -0,0 +1,89 @@
+package reader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestReader_ReadFiles(t *testing.T) {
+	input := `
+files:
+  KEYSTORE:
+    value: aGVsbG8=
+    base64: true
+    name: keystore.jks
+  CONFIG: "a: 1"
+  CREDENTIALS:
+    path: secret/gcp
+environments:
+  stage:
+    files:
+      CONFIG: "a: 2"
+`
+	var data Variables
+	if err := yaml.Unmarshal([]byte(input), &data); err != nil {
+		t.Fatalf("yaml.Unmarshal() error = %v", err)
+	}
+	reader, _ := NewReader(WithSkipVault(true))
+	got, err := reader.Read(context.Background(), &data, "stage", "")
+	if err != nil {
+		t.Fatalf("Reader.Read() error = %v", err)
+	}
+
+	// Replace each path with the file's name and contents
+	dirs := []string{}
+	for i, out := range got {
+		if out.Key == "" {
+			continue
+		}
+		info, err := os.Stat(out.Value)
+		if err != nil {
+			t.Fatalf("os.Stat() error = %v", err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("%s mode = %v, want 0600", out.Key, info.Mode().Perm())
+		}
+		contents, _ := os.ReadFile(out.Value)
+		dirs = append(dirs, filepath.Dir(filepath.Dir(out.Value)))
+		got[i].Value = filepath.Base(out.Value) + "=" + string(contents)
+	}
+	want := OutputList{
+		{Comment: "Global Variables"},
+		{Key: "KEYSTORE", Value: "keystore.jks=hello", Comment: "File"},
+		{Key: "CONFIG", Value: "CONFIG=a: 1", Comment: "File"},
+		{Comment: "Environment: stage"},
+		{Key: "CONFIG", Value: "CONFIG=a: 2", Comment: "File"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Reader.Read() = %v, want %v", got, want)
+	}
+
+	info, err := os.Stat(dirs[0])
+	if err != nil {
+		t.Fatalf("os.Stat() error = %v", err)
+	}
+	if info.Mode().Perm() != 0700 {
+		t.Errorf("directory mode = %v, want 0700", info.Mode().Perm())
+	}
+	if err := reader.Cleanup(); err != nil {
+		t.Fatalf("Reader.Cleanup() error = %v", err)
+	}
+	if _, err := os.Stat(dirs[0]); !os.IsNotExist(err) {
+		t.Errorf("directory %s still exists after Cleanup()", dirs[0])
+	}
+}
+
+func TestReader_writeFile(t *testing.T) {
+	reader, _ := NewReader()
+	defer reader.Cleanup()
+	for _, name := range []string{"../escape", "a/b", "..", ""} {
+		if _, err := reader.writeFile(name, nil); err == nil {
+			t.Errorf("Reader.writeFile(%q) error = nil, want an error", name)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package reader
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// FileVarBlock maps environment variables to selectors within a JSON, YAML or
+// TOML file. Selectors starting with "/" are JSON pointers, anything else is a
+// dotted path such as "db.hosts.0".
+type FileVarBlock struct {
+	Path   string
+	Format string `yaml:"format,omitempty"`
+	Vars   KVSecret
+}
+
+// FileVars is a list of structured file sources
+type FileVars []FileVarBlock
+
+func (s FileVarBlock) GetOutput() (OutputList, error) {
+	output := OutputList{}
+
+	doc, err := readStructuredFile(s.Path, s.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range s.Vars {
+		varName, selector := v.Name, v.Value
+		selected, err := Select(doc, selector)
+		if err != nil {
+			return nil, fmt.Errorf("%s in file %s: %w", varName, s.Path, err)
+		}
+		val, err := renderSelected(selected)
+		if err != nil {
+			return nil, fmt.Errorf("%s in file %s: %w", varName, s.Path, err)
+		}
+		output = append(output, Output{
+			Key:     varName,
+			Value:   val,
+			Comment: fmt.Sprintf("File: %s, Selector: %s", s.Path, selector),
+		})
+	}
+
+	return output, nil
+}
+
+func (s FileVars) GetOutput() (OutputList, error) {
+	output := OutputList{}
+	for _, block := range s {
+		blockOutput, err := block.GetOutput()
+		if err != nil {
+			return nil, err
+		}
+		output = append(output, blockOutput...)
+	}
+	return output, nil
+}
+
+// readStructuredFile decodes a file into generic maps and slices, using the
+// extension when no format is given
+func readStructuredFile(path string, format string) (interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read file %s: %w", path, err)
+	}
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+
+	var doc interface{}
+	switch format {
+	case "json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		err = decoder.Decode(&doc)
+	case "yaml", "yml":
+		err = yaml.Unmarshal(data, &doc)
+	case "toml":
+		err = toml.Unmarshal(data, &doc)
+	default:
+		return nil, fmt.Errorf("unknown format for file %s, set format to json, yaml or toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s file %s: %w", format, path, err)
+	}
+	return doc, nil
+}
+
+// Select finds the value addressed by a JSON pointer ("/a/0/b") or a dotted
+// path ("a.0.b") within a decoded document
+func Select(doc interface{}, selector string) (interface{}, error) {
+	var parts []string
+	if strings.HasPrefix(selector, "/") {
+		for _, part := range strings.Split(selector[1:], "/") {
+			part = strings.ReplaceAll(part, "~1", "/")
+			part = strings.ReplaceAll(part, "~0", "~")
+			parts = append(parts, part)
+		}
+	} else if selector != "" {
+		parts = strings.Split(selector, ".")
+	}
+
+	current := doc
+	for i, part := range parts {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			next, found := node[part]
+			if !found {
+				return nil, fmt.Errorf("selector %s not found: no key %q", selector, strings.Join(parts[:i+1], "."))
+			}
+			current = next
+		case []interface{}:
+			idx, err := strconv.Atoi(part)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return nil, fmt.Errorf("selector %s not found: no index %q", selector, strings.Join(parts[:i+1], "."))
+			}
+			current = node[idx]
+		default:
+			parent := "document root"
+			if i > 0 {
+				parent = strings.Join(parts[:i], ".")
+			}
+			return nil, fmt.Errorf("selector %s not found: %s is not an object or array", selector, parent)
+		}
+	}
+	return current, nil
+}
+
+// renderSelected returns strings as they are and anything else as JSON
+func renderSelected(val interface{}) (string, error) {
+	if str, isString := val.(string); isString {
+		return str, nil
+	}
+	encoded, err := json.Marshal(val)
+	if err != nil {
+		return "", fmt.Errorf("unable to render value as JSON: %w", err)
+	}
+	return string(encoded), nil
+}
@@ -0,0 +1,134 @@
+package reader
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFileVarBlock_GetOutput(t *testing.T) {
+	dir := t.TempDir()
+	jsonFile := filepath.Join(dir, "out.json")
+	os.WriteFile(jsonFile, []byte(`{
+  "db_host": {"sensitive": false, "type": "string", "value": "db.internal"},
+  "db_port": {"value": 5432},
+  "zones": {"value": ["a", "b"]},
+  "odd/key": {"value": "slash"}
+}`), 0600)
+	yamlFile := filepath.Join(dir, "conf.yaml")
+	os.WriteFile(yamlFile, []byte("app:\n  hosts:\n    - one\n    - two\n  debug: true\n"), 0600)
+	tomlFile := filepath.Join(dir, "conf.toml")
+	os.WriteFile(tomlFile, []byte("[server]\nname = \"web\"\nports = [80, 443]\n"), 0600)
+	noExt := filepath.Join(dir, "outputs")
+	os.WriteFile(noExt, []byte(`{"a": "b"}`), 0600)
+
+	tests := []struct {
+		name    string
+		block   FileVarBlock
+		want    OutputList
+		wantErr bool
+	}{
+		{
+			name: "JSON Pointers",
+			block: FileVarBlock{
+				Path: jsonFile,
+				Vars: KVSecret{
+					{"DB_HOST", "/db_host/value"},
+					{"DB_PORT", "/db_port/value"},
+					{"ZONES", "/zones/value"},
+					{"ODD", "/odd~1key/value"},
+				},
+			},
+			want: OutputList{
+				{Key: "DB_HOST", Value: "db.internal", Comment: "File: " + jsonFile + ", Selector: /db_host/value"},
+				{Key: "DB_PORT", Value: "5432", Comment: "File: " + jsonFile + ", Selector: /db_port/value"},
+				{Key: "ZONES", Value: `["a","b"]`, Comment: "File: " + jsonFile + ", Selector: /zones/value"},
+				{Key: "ODD", Value: "slash", Comment: "File: " + jsonFile + ", Selector: /odd~1key/value"},
+			},
+		},
+		{
+			name: "YAML Dotted",
+			block: FileVarBlock{
+				Path: yamlFile,
+				Vars: KVSecret{
+					{"HOST", "app.hosts.1"},
+					{"DEBUG", "app.debug"},
+				},
+			},
+			want: OutputList{
+				{Key: "HOST", Value: "two", Comment: "File: " + yamlFile + ", Selector: app.hosts.1"},
+				{Key: "DEBUG", Value: "true", Comment: "File: " + yamlFile + ", Selector: app.debug"},
+			},
+		},
+		{
+			name: "TOML",
+			block: FileVarBlock{
+				Path: tomlFile,
+				Vars: KVSecret{
+					{"NAME", "server.name"},
+					{"PORTS", "/server/ports"},
+				},
+			},
+			want: OutputList{
+				{Key: "NAME", Value: "web", Comment: "File: " + tomlFile + ", Selector: server.name"},
+				{Key: "PORTS", Value: "[80,443]", Comment: "File: " + tomlFile + ", Selector: /server/ports"},
+			},
+		},
+		{
+			name: "Explicit Format",
+			block: FileVarBlock{
+				Path:   noExt,
+				Format: "json",
+				Vars:   KVSecret{{"A", "a"}},
+			},
+			want: OutputList{
+				{Key: "A", Value: "b", Comment: "File: " + noExt + ", Selector: a"},
+			},
+		},
+		{
+			name: "Unknown Format",
+			block: FileVarBlock{
+				Path: noExt,
+				Vars: KVSecret{{"A", "a"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Missing Selector",
+			block: FileVarBlock{
+				Path: jsonFile,
+				Vars: KVSecret{{"NOPE", "/db_host/nope"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Index Out Of Range",
+			block: FileVarBlock{
+				Path: yamlFile,
+				Vars: KVSecret{{"NOPE", "app.hosts.5"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Missing File",
+			block: FileVarBlock{
+				Path: filepath.Join(dir, "missing.json"),
+				Vars: KVSecret{{"A", "a"}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.block.GetOutput()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("FileVarBlock.GetOutput() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FileVarBlock.GetOutput() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,516 @@
+package reader
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/hcl/ast"
+	hclparser "github.com/hashicorp/hcl/hcl/parser"
+	"github.com/hashicorp/hcl/hcl/token"
+	"github.com/pelletier/go-toml/v2/unstable"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats variables files can be written in
+const (
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+	FormatTOML = "toml"
+	FormatHCL  = "hcl"
+)
+
+// InputFormats are the formats variables files can be written in
+var InputFormats = []string{FormatYAML, FormatJSON, FormatTOML, FormatHCL}
+
+// WithInputFormat sets the format of the variables files loaded, in place of
+// detecting it from their extensions. Included files are still detected.
+func WithInputFormat(format string) ReaderOptFunc {
+	return func(r *Reader) {
+		r.inputFormat = format
+	}
+}
+
+// fileFormat detects a variables file's format from its extension, or the
+// key's for a definition in Vault. Anything else is YAML.
+func fileFormat(path string) string {
+	if isRemote(path) {
+		definition, err := parseRemote(path)
+		if err != nil {
+			return FormatYAML
+		}
+		path = definition.key
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON
+	case ".toml":
+		return FormatTOML
+	case ".hcl":
+		return FormatHCL
+	}
+	return FormatYAML
+}
+
+// parseDocument parses a variables file into a YAML document, so every
+// format is validated and decoded the same way. Nodes keep the line and
+// column they came from.
+func parseDocument(raw []byte, format string) (*yaml.Node, error) {
+	var doc *yaml.Node
+	var err error
+	switch format {
+	case FormatYAML:
+		doc = &yaml.Node{}
+		err = yaml.Unmarshal(raw, doc)
+	case FormatJSON:
+		doc, err = parseJSON(raw)
+	case FormatTOML:
+		doc, err = parseTOML(raw)
+	case FormatHCL:
+		doc, err = parseHCL(raw)
+	default:
+		return nil, fmt.Errorf("unknown input format %s, use one of %s", format, strings.Join(InputFormats, ", "))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// positionError is a parse error at a line and column
+type positionError struct {
+	line    int
+	column  int
+	message string
+}
+
+func (e *positionError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.line, e.column, e.message)
+}
+
+// position finds the line and column of an offset into data
+func position(data []byte, offset int) (int, int) {
+	offset = min(max(offset, 0), len(data))
+	lead := data[:offset]
+	return bytes.Count(lead, []byte{'\n'}) + 1, len(lead) - bytes.LastIndexByte(lead, '\n')
+}
+
+func document(root *yaml.Node) *yaml.Node {
+	return &yaml.Node{Kind: yaml.DocumentNode, Line: 1, Column: 1, Content: []*yaml.Node{root}}
+}
+
+func mappingNode(line int, column int) *yaml.Node {
+	return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: line, Column: column}
+}
+
+func sequenceNode(line int, column int) *yaml.Node {
+	return &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Line: line, Column: column}
+}
+
+func scalarNode(line int, column int, tag string, value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value, Line: line, Column: column}
+}
+
+// childMapping finds the mapping under a key, adding it if it isn't there
+func childMapping(mapping *yaml.Node, key *yaml.Node) (*yaml.Node, error) {
+	child := mappingValue(mapping, key.Value)
+	if child == nil {
+		child = mappingNode(key.Line, key.Column)
+		mapping.Content = append(mapping.Content, key, child)
+	}
+	if child.Kind == yaml.SequenceNode && len(child.Content) > 0 {
+		// A key within the last of an array of tables
+		child = child.Content[len(child.Content)-1]
+	}
+	if child.Kind != yaml.MappingNode {
+		return nil, &positionError{key.Line, key.Column, fmt.Sprintf("%s is already defined as a value", key.Value)}
+	}
+	return child, nil
+}
+
+// addValue adds a key's value to a mapping, which mustn't already have it
+func addValue(mapping *yaml.Node, key *yaml.Node, value *yaml.Node) error {
+	if mappingValue(mapping, key.Value) != nil {
+		return &positionError{key.Line, key.Column, fmt.Sprintf("%s is already defined", key.Value)}
+	}
+	mapping.Content = append(mapping.Content, key, value)
+	return nil
+}
+
+// jsonParser converts JSON into YAML nodes. The decoder reads tokens, and
+// their positions are found from its offsets into the data.
+type jsonParser struct {
+	data    []byte
+	decoder *json.Decoder
+}
+
+func parseJSON(data []byte) (*yaml.Node, error) {
+	p := &jsonParser{data: data, decoder: json.NewDecoder(bytes.NewReader(data))}
+	p.decoder.UseNumber()
+	if len(bytes.TrimSpace(data)) == 0 {
+		return &yaml.Node{}, nil
+	}
+	root, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	if _, offset, err := p.next(); err != io.EOF {
+		if err != nil {
+			return nil, err
+		}
+		line, column := position(data, offset)
+		return nil, &positionError{line, column, "unexpected data after the top-level value"}
+	}
+	return document(root), nil
+}
+
+// next reads a token, and the offset it starts at
+func (p *jsonParser) next() (json.Token, int, error) {
+	offset := int(p.decoder.InputOffset())
+	token, err := p.decoder.Token()
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		line, column := position(p.data, int(syntaxErr.Offset)-1)
+		return nil, 0, &positionError{line, column, syntaxErr.Error()}
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		line, column := position(p.data, len(p.data))
+		return nil, 0, &positionError{line, column, "unexpected end of JSON input"}
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	for offset < len(p.data) && strings.IndexByte(" \t\r\n,:", p.data[offset]) >= 0 {
+		offset++
+	}
+	return token, offset, nil
+}
+
+func (p *jsonParser) value() (*yaml.Node, error) {
+	token, offset, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	line, column := position(p.data, offset)
+	switch value := token.(type) {
+	case json.Delim:
+		if value == '[' {
+			sequence := sequenceNode(line, column)
+			for p.decoder.More() {
+				item, err := p.value()
+				if err != nil {
+					return nil, err
+				}
+				sequence.Content = append(sequence.Content, item)
+			}
+			_, _, err := p.next()
+			return sequence, err
+		}
+		mapping := mappingNode(line, column)
+		for p.decoder.More() {
+			token, offset, err := p.next()
+			if err != nil {
+				return nil, err
+			}
+			keyLine, keyColumn := position(p.data, offset)
+			key := scalarNode(keyLine, keyColumn, "!!str", token.(string))
+			item, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			if err := addValue(mapping, key, item); err != nil {
+				return nil, err
+			}
+		}
+		_, _, err := p.next()
+		return mapping, err
+	case string:
+		return scalarNode(line, column, "!!str", value), nil
+	case json.Number:
+		if strings.ContainsAny(value.String(), ".eE") {
+			return scalarNode(line, column, "!!float", value.String()), nil
+		}
+		return scalarNode(line, column, "!!int", value.String()), nil
+	case bool:
+		return scalarNode(line, column, "!!bool", strconv.FormatBool(value)), nil
+	}
+	return scalarNode(line, column, "!!null", "null"), nil
+}
+
+// tomlParser converts TOML into YAML nodes
+type tomlParser struct {
+	data   []byte
+	parser unstable.Parser
+	// defined holds the tables a [table] header has defined, which can't be
+	// defined again
+	defined map[*yaml.Node]bool
+}
+
+func parseTOML(data []byte) (*yaml.Node, error) {
+	p := &tomlParser{data: data, defined: map[*yaml.Node]bool{}}
+	p.parser.Reset(data)
+	root := mappingNode(1, 1)
+	table := root
+	for p.parser.NextExpression() {
+		expression := p.parser.Expression()
+		var err error
+		switch expression.Kind {
+		case unstable.KeyValue:
+			err = p.keyValue(table, expression)
+		case unstable.Table:
+			table, err = p.table(root, expression.Key(), false)
+		case unstable.ArrayTable:
+			table, err = p.table(root, expression.Key(), true)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	var parserErr *unstable.ParserError
+	if errors.As(p.parser.Error(), &parserErr) {
+		line, column := position(data, len(data))
+		if len(parserErr.Highlight) > 0 {
+			line, column = p.position(p.parser.Range(parserErr.Highlight))
+		}
+		return nil, &positionError{line, column, parserErr.Message}
+	} else if err := p.parser.Error(); err != nil {
+		return nil, err
+	}
+	return document(root), nil
+}
+
+func (p *tomlParser) position(r unstable.Range) (int, int) {
+	return position(p.data, int(r.Offset))
+}
+
+func (p *tomlParser) key(key *unstable.Node) *yaml.Node {
+	line, column := p.position(key.Raw)
+	return scalarNode(line, column, "!!str", string(key.Data))
+}
+
+// table finds the table a [table] or [[array table]] header starts
+func (p *tomlParser) table(root *yaml.Node, keys unstable.Iterator, isArray bool) (*yaml.Node, error) {
+	table := root
+	for keys.Next() {
+		key := p.key(keys.Node())
+		if !isArray || !keys.IsLast() {
+			var err error
+			if table, err = childMapping(table, key); err != nil {
+				return nil, err
+			}
+			if !isArray && keys.IsLast() {
+				if p.defined[table] {
+					return nil, &positionError{key.Line, key.Column, fmt.Sprintf("table %s is already defined", key.Value)}
+				}
+				p.defined[table] = true
+			}
+			continue
+		}
+		tables := mappingValue(table, key.Value)
+		if tables == nil {
+			tables = sequenceNode(key.Line, key.Column)
+			table.Content = append(table.Content, key, tables)
+		}
+		if tables.Kind != yaml.SequenceNode {
+			return nil, &positionError{key.Line, key.Column, fmt.Sprintf("%s is already defined as a table", key.Value)}
+		}
+		table = mappingNode(key.Line, key.Column)
+		tables.Content = append(tables.Content, table)
+	}
+	return table, nil
+}
+
+// keyValue adds a key = value expression to a table, dotted keys adding
+// tables of their own
+func (p *tomlParser) keyValue(table *yaml.Node, expression *unstable.Node) error {
+	keys := expression.Key()
+	for keys.Next() {
+		key := p.key(keys.Node())
+		if !keys.IsLast() {
+			var err error
+			if table, err = childMapping(table, key); err != nil {
+				return err
+			}
+			continue
+		}
+		value, err := p.value(expression.Value(), key)
+		if err != nil {
+			return err
+		}
+		return addValue(table, key, value)
+	}
+	return nil
+}
+
+// value converts a TOML value. Values without a position of their own are
+// placed at their key.
+func (p *tomlParser) value(node *unstable.Node, key *yaml.Node) (*yaml.Node, error) {
+	line, column := key.Line, key.Column
+	if node.Raw.Length > 0 {
+		line, column = p.position(node.Raw)
+	}
+	data := string(node.Data)
+	switch node.Kind {
+	case unstable.String, unstable.LocalDate, unstable.LocalTime, unstable.LocalDateTime, unstable.DateTime:
+		return scalarNode(line, column, "!!str", data), nil
+	case unstable.Bool:
+		return scalarNode(line, column, "!!bool", data), nil
+	case unstable.Integer:
+		value, err := strconv.ParseInt(strings.ReplaceAll(data, "_", ""), 0, 64)
+		if err != nil {
+			return nil, &positionError{line, column, fmt.Sprintf("invalid integer %s", data)}
+		}
+		return scalarNode(line, column, "!!int", strconv.FormatInt(value, 10)), nil
+	case unstable.Float:
+		value, err := strconv.ParseFloat(strings.ReplaceAll(data, "_", ""), 64)
+		if err != nil {
+			return nil, &positionError{line, column, fmt.Sprintf("invalid float %s", data)}
+		}
+		return scalarNode(line, column, "!!float", yamlFloat(value)), nil
+	case unstable.Array:
+		sequence := sequenceNode(line, column)
+		for items := node.Children(); items.Next(); {
+			item, err := p.value(items.Node(), key)
+			if err != nil {
+				return nil, err
+			}
+			sequence.Content = append(sequence.Content, item)
+		}
+		return sequence, nil
+	case unstable.InlineTable:
+		mapping := mappingNode(line, column)
+		for items := node.Children(); items.Next(); {
+			if err := p.keyValue(mapping, items.Node()); err != nil {
+				return nil, err
+			}
+		}
+		return mapping, nil
+	}
+	return nil, &positionError{line, column, fmt.Sprintf("unsupported value %s", node.Kind)}
+}
+
+// yamlFloat writes a float the way YAML reads it back
+func yamlFloat(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		return ".nan"
+	case math.IsInf(value, 1):
+		return ".inf"
+	case math.IsInf(value, -1):
+		return "-.inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// hclBlockLists are the sections that are lists of blocks, so repeating one
+// in HCL adds another block rather than merging with the last
+var hclBlockLists = []string{"file_vars", "kv_secrets", "kv1_secrets"}
+
+func parseHCL(data []byte) (*yaml.Node, error) {
+	file, err := hclparser.Parse(data)
+	var posErr *hclparser.PosError
+	if errors.As(err, &posErr) {
+		return nil, &positionError{posErr.Pos.Line, posErr.Pos.Column, posErr.Err.Error()}
+	} else if err != nil {
+		return nil, err
+	}
+	root := mappingNode(1, 1)
+	if list, ok := file.Node.(*ast.ObjectList); ok {
+		if err := hclObject(root, list); err != nil {
+			return nil, err
+		}
+	}
+	return document(root), nil
+}
+
+// hclObject adds the items of an HCL object to a mapping. Labels are nested
+// keys, so environments "stage" { ... } is the stage environment.
+func hclObject(mapping *yaml.Node, list *ast.ObjectList) error {
+	for _, item := range list.Items {
+		parent := mapping
+		for _, label := range item.Keys[:len(item.Keys)-1] {
+			var err error
+			if parent, err = childMapping(parent, hclKey(label)); err != nil {
+				return err
+			}
+		}
+		key := hclKey(item.Keys[len(item.Keys)-1])
+		value, err := hclValue(item.Val)
+		if err != nil {
+			return err
+		}
+		_, isBlock := item.Val.(*ast.ObjectType)
+		existing := mappingValue(parent, key.Value)
+		switch {
+		case isBlock && slices.Contains(hclBlockLists, key.Value) && existing == nil:
+			blocks := sequenceNode(key.Line, key.Column)
+			blocks.Content = []*yaml.Node{value}
+			parent.Content = append(parent.Content, key, blocks)
+		case isBlock && existing != nil && existing.Kind == yaml.SequenceNode && slices.Contains(hclBlockLists, key.Value):
+			existing.Content = append(existing.Content, value)
+		case isBlock && existing != nil && existing.Kind == yaml.MappingNode:
+			// A block repeated with more of its contents
+			for i := 0; i+1 < len(value.Content); i += 2 {
+				if err := addValue(existing, value.Content[i], value.Content[i+1]); err != nil {
+					return err
+				}
+			}
+		default:
+			if err := addValue(parent, key, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func hclKey(key *ast.ObjectKey) *yaml.Node {
+	name := key.Token.Text
+	if key.Token.Type == token.STRING {
+		name, _ = key.Token.Value().(string)
+	}
+	return scalarNode(key.Token.Pos.Line, key.Token.Pos.Column, "!!str", name)
+}
+
+func hclValue(node ast.Node) (*yaml.Node, error) {
+	line, column := node.Pos().Line, node.Pos().Column
+	switch node := node.(type) {
+	case *ast.ObjectType:
+		mapping := mappingNode(line, column)
+		return mapping, hclObject(mapping, node.List)
+	case *ast.ListType:
+		sequence := sequenceNode(line, column)
+		for _, item := range node.List {
+			value, err := hclValue(item)
+			if err != nil {
+				return nil, err
+			}
+			sequence.Content = append(sequence.Content, value)
+		}
+		return sequence, nil
+	case *ast.LiteralType:
+		switch node.Token.Type {
+		case token.STRING, token.HEREDOC:
+			value, _ := node.Token.Value().(string)
+			return scalarNode(line, column, "!!str", value), nil
+		case token.NUMBER:
+			value, err := strconv.ParseInt(node.Token.Text, 0, 64)
+			if err != nil {
+				return nil, &positionError{line, column, fmt.Sprintf("invalid number %s", node.Token.Text)}
+			}
+			return scalarNode(line, column, "!!int", strconv.FormatInt(value, 10)), nil
+		case token.FLOAT:
+			return scalarNode(line, column, "!!float", node.Token.Text), nil
+		case token.BOOL:
+			return scalarNode(line, column, "!!bool", node.Token.Text), nil
+		}
+	}
+	return nil, &positionError{line, column, "unsupported value"}
+}
@@ -0,0 +1,229 @@
+package reader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadVariables_Formats(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"variables.yml": `
+vars:
+  APP: "demo"
+  PORT: 8080
+  DEBUG: true
+kv_secrets:
+  - path: "secret/one"
+    vars:
+      ONE: "one"
+  - path: "secret/two"
+    vars:
+      TWO: "two"
+environments:
+  stage:
+    vars:
+      ENV: "stage"
+    dcs:
+      us-east-1:
+        vars:
+          DC: "us-east-1"
+`,
+		"variables.json": `{
+  "vars": {"APP": "demo", "PORT": 8080, "DEBUG": true},
+  "kv_secrets": [
+    {"path": "secret/one", "vars": {"ONE": "one"}},
+    {"path": "secret/two", "vars": {"TWO": "two"}}
+  ],
+  "environments": {
+    "stage": {
+      "vars": {"ENV": "stage"},
+      "dcs": {"us-east-1": {"vars": {"DC": "us-east-1"}}}
+    }
+  }
+}
+`,
+		"variables.toml": `
+[vars]
+APP = "demo"
+PORT = 8_080
+DEBUG = true
+
+[[kv_secrets]]
+path = "secret/one"
+vars = { ONE = "one" }
+
+[[kv_secrets]]
+path = "secret/two"
+[kv_secrets.vars]
+TWO = "two"
+
+[environments.stage]
+vars.ENV = "stage"
+
+[environments.stage.dcs.us-east-1.vars]
+DC = "us-east-1"
+`,
+		"variables.hcl": `
+vars {
+  APP = "demo"
+  PORT = 8080
+  DEBUG = true
+}
+
+kv_secrets {
+  path = "secret/one"
+  vars { ONE = "one" }
+}
+
+kv_secrets {
+  path = "secret/two"
+  vars { TWO = "two" }
+}
+
+environments "stage" {
+  vars { ENV = "stage" }
+  dcs "us-east-1" {
+    vars { DC = "us-east-1" }
+  }
+}
+`,
+	})
+	want, err := LoadVariables(filepath.Join(dir, "variables.yml"))
+	if err != nil {
+		t.Fatalf("LoadVariables() error = %v", err)
+	}
+	for _, file := range []string{"variables.json", "variables.toml", "variables.hcl"} {
+		t.Run(file, func(t *testing.T) {
+			got, err := LoadVariables(filepath.Join(dir, file))
+			if err != nil {
+				t.Fatalf("LoadVariables() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.Vars, want.Vars) {
+				t.Errorf("Vars = %v, want %v", got.Vars, want.Vars)
+			}
+			if !reflect.DeepEqual(got.KVSecrets, want.KVSecrets) {
+				t.Errorf("KVSecrets = %v, want %v", got.KVSecrets, want.KVSecrets)
+			}
+			if !reflect.DeepEqual(got.Environments, want.Environments) {
+				t.Errorf("Environments = %v, want %v", got.Environments, want.Environments)
+			}
+		})
+	}
+}
+
+func TestLoadVariables_FormatErrors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"syntax.json":    "{\n  \"vars\": {\"APP\": \"demo\",}\n}\n",
+		"duplicate.json": "{\n  \"vars\": {\"APP\": \"a\",\n    \"APP\": \"b\"}\n}\n",
+		"trailing.json":  "{}\n{}\n",
+		"syntax.toml":    "[vars\nAPP = \"demo\"\n",
+		"duplicate.toml": "[vars]\nAPP = \"a\"\n  APP = \"b\"\n",
+		"table.toml":     "[environments.stage]\nvars = { ENV = \"stage\" }\n\n[vars]\nAPP = \"a\"\n\n[environments.stage]\nsecrets = { TOKEN = \"secret/token\" }\n",
+		"syntax.hcl":     "vars {\n  APP = \n}\n",
+		"unknown.hcl":    "vars {\n  APP = \"a\"\n}\n\nenvironments \"stage\" {\n  varz { ENV = \"stage\" }\n}\n",
+		"unknown.toml":   "[environments.stage]\nvarz = { ENV = \"stage\" }\n",
+	})
+	tests := []struct {
+		file    string
+		wantErr string
+	}{
+		{file: "syntax.json", wantErr: "unable to parse JSON file " + filepath.Join(dir, "syntax.json") + ": line 2, column 25: "},
+		{file: "duplicate.json", wantErr: "line 3, column 5: APP is already defined"},
+		{file: "trailing.json", wantErr: "line 2, column 1: unexpected data after the top-level value"},
+		{file: "syntax.toml", wantErr: "unable to parse TOML file " + filepath.Join(dir, "syntax.toml") + ": line 1, column 6: "},
+		{file: "duplicate.toml", wantErr: "line 3, column 3: APP is already defined"},
+		{file: "table.toml", wantErr: "line 7, column 15: table stage is already defined"},
+		{file: "syntax.hcl", wantErr: "unable to parse HCL file " + filepath.Join(dir, "syntax.hcl") + ": line 4, column 1: "},
+		{file: "unknown.hcl", wantErr: filepath.Join(dir, "unknown.hcl") + ":6:3: unknown field \"varz\" in an environment"},
+		{file: "unknown.toml", wantErr: filepath.Join(dir, "unknown.toml") + ":2:1: unknown field \"varz\" in an environment"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			_, err := LoadVariables(filepath.Join(dir, tt.file))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadVariables() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReader_LoadVariablesInputFormat(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"variables":     "{\"include\": [\"included.toml\"], \"vars\": {\"FROM\": \"json\"}}\n",
+		"included.toml": "[vars]\nINCLUDED = \"toml\"\n",
+	})
+	reader, _ := NewReader(WithInputFormat(FormatJSON))
+	got, err := reader.LoadVariables(context.Background(), filepath.Join(dir, "variables"))
+	if err != nil {
+		t.Fatalf("Reader.LoadVariables() error = %v", err)
+	}
+	if want := (EnvVars{{"INCLUDED", "toml"}, {"FROM", "json"}}); !reflect.DeepEqual(got.Vars, want) {
+		t.Errorf("Vars = %v, want %v", got.Vars, want)
+	}
+
+	reader, _ = NewReader(WithInputFormat("xml"))
+	if _, err := reader.LoadVariables(context.Background(), filepath.Join(dir, "variables")); err == nil || !strings.Contains(err.Error(), "unknown input format xml") {
+		t.Errorf("Reader.LoadVariables() error = %v, want unknown input format", err)
+	}
+}
+
+func TestValidateFile_Formats(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"variables.json": "{\n  \"vars\": {\"APP\": \"demo\"},\n  \"varz\": {}\n}\n",
+		"broken.toml":    "[vars]\nAPP = \"demo\nPORT = 8080\n",
+	})
+	tests := []struct {
+		file string
+		want []Problem
+	}{
+		{
+			file: "variables.json",
+			want: []Problem{{Line: 3, Column: 3, Message: `unknown field "varz" in the variables file`}},
+		},
+		{
+			file: "broken.toml",
+			want: []Problem{{Line: 2, Column: 12, Message: "basic strings cannot have new lines"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			for i := range tt.want {
+				tt.want[i].File = path
+			}
+			if got := ValidateFile(path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateFile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateFileFormat(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"variables.conf": "{\n  \"include\": [\"included.toml\"],\n  \"varz\": {}\n}\n",
+		"included.toml":  "[vars]\nINCLUDED = \"toml\"\n",
+	})
+	path := filepath.Join(dir, "variables.conf")
+	want := []Problem{{File: path, Line: 3, Column: 3, Message: `unknown field "varz" in the variables file`}}
+	if got := ValidateFileFormat(path, FormatJSON); !reflect.DeepEqual(got, want) {
+		t.Errorf("ValidateFileFormat() = %v, want %v", got, want)
+	}
+
+	if err := os.WriteFile(path, []byte("{\"include\": [\"included.toml\"], \"vars\": {\"FROM\": \"json\"}}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if got := ValidateFileFormat(path, FormatJSON); len(got) > 0 {
+		t.Errorf("ValidateFileFormat() = %v, want no problems", got)
+	}
+	got, err := LoadVariablesFormat(FormatJSON, path)
+	if err != nil {
+		t.Fatalf("LoadVariablesFormat() error = %v", err)
+	}
+	if want := (EnvVars{{"INCLUDED", "toml"}, {"FROM", "json"}}); !reflect.DeepEqual(got.Vars, want) {
+		t.Errorf("Vars = %v, want %v", got.Vars, want)
+	}
+}
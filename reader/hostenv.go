@@ -0,0 +1,74 @@
+package reader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrMissingHostVars is returned when required or from_env variables aren't
+// set in the caller's environment
+var ErrMissingHostVars = errors.New("required variables are not set in the environment")
+
+// FromEnv maps variables to the names of variables in the caller's
+// environment to copy them from, e.g. APP_TOKEN: CI_JOB_TOKEN
+type FromEnv []Var
+
+func (f *FromEnv) UnmarshalYAML(node *yaml.Node) error {
+	vars, err := decodeVars[string](node, "from_env", nil, nil)
+	*f = vars
+	return err
+}
+
+func (f FromEnv) MarshalJSON() ([]byte, error) {
+	return marshalVars(f)
+}
+
+// checkEnvironment makes sure every required variable, and every variable
+// copied with from_env, is set in the caller's environment, naming all of
+// the missing ones at once
+func checkEnvironment(selected []selectedScope) error {
+	missing := []string{}
+	for _, node := range selected {
+		for _, name := range node.scope.Required {
+			if _, found := os.LookupEnv(name); !found && !slices.Contains(missing, name) {
+				missing = append(missing, name)
+			}
+		}
+		for _, v := range node.scope.FromEnv {
+			entry := fmt.Sprintf("%s (for %s)", v.Value, v.Name)
+			if _, found := os.LookupEnv(v.Value); !found && !slices.Contains(missing, entry) {
+				missing = append(missing, entry)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s", ErrMissingHostVars, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// hostOutput passes on required variables and copies from_env variables from
+// the caller's environment
+func (s sourcedScope) hostOutput() OutputList {
+	output := OutputList{}
+	for _, name := range s.Required {
+		output = append(output, s.annotateOutput(Output{
+			Key:     name,
+			Value:   os.Getenv(name),
+			Comment: "Required from environment",
+		}, "required."+name))
+	}
+	for _, v := range s.FromEnv {
+		output = append(output, s.annotateOutput(Output{
+			Key:     v.Name,
+			Value:   os.Getenv(v.Value),
+			Comment: fmt.Sprintf("From environment: %s", v.Value),
+		}, "from_env."+v.Name))
+	}
+	return output
+}
@@ -0,0 +1,55 @@
+package reader
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestReader_ReadHostEnv(t *testing.T) {
+	input := `
+required: [BUILD_NUMBER]
+from_env:
+  APP_TOKEN: CI_JOB_TOKEN
+environments:
+  ci:
+    required: [BUILD_URL, BUILD_NUMBER]
+    from_env:
+      RUNNER: CI_RUNNER
+`
+	var data Variables
+	if err := yaml.Unmarshal([]byte(input), &data); err != nil {
+		t.Fatalf("yaml.Unmarshal() error = %v", err)
+	}
+	reader, _ := NewReader(WithSkipVault(true))
+
+	t.Setenv("BUILD_NUMBER", "42")
+	t.Setenv("CI_JOB_TOKEN", "token")
+	_, err := reader.Read(context.Background(), &data, "ci", "")
+	wantErr := "required variables are not set in the environment: BUILD_URL, CI_RUNNER (for RUNNER)"
+	if !errors.Is(err, ErrMissingHostVars) || err.Error() != wantErr {
+		t.Errorf("Reader.Read() error = %v, want %v", err, wantErr)
+	}
+
+	t.Setenv("BUILD_URL", "https://ci/42")
+	t.Setenv("CI_RUNNER", "")
+	got, err := reader.Read(context.Background(), &data, "ci", "")
+	if err != nil {
+		t.Fatalf("Reader.Read() error = %v", err)
+	}
+	want := OutputList{
+		{Comment: "Global Variables"},
+		{Key: "BUILD_NUMBER", Value: "42", Comment: "Required from environment"},
+		{Key: "APP_TOKEN", Value: "token", Comment: "From environment: CI_JOB_TOKEN"},
+		{Comment: "Environment: ci"},
+		{Key: "BUILD_URL", Value: "https://ci/42", Comment: "Required from environment"},
+		{Key: "BUILD_NUMBER", Value: "42", Comment: "Required from environment"},
+		{Key: "RUNNER", Value: "", Comment: "From environment: CI_RUNNER"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Reader.Read() = %v, want %v", got, want)
+	}
+}
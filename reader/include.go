@@ -0,0 +1,431 @@
+package reader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// LoadVariables reads variables files, and everything they include, merging
+// them in order so later files win. A path ending in "?" is optional, and
+// skipped if the file doesn't exist. Included files are merged first, in
+// order, and the including file is merged last:
+//
+//   - vars (plain and encrypted), secrets, exec_vars and files are merged by
+//     variable name, the later file winning
+//   - kv_secrets, kv1_secrets, file_vars and env_files lists are concatenated
+//   - environments, dcs and scopes are merged by name using the same rules
+//
+// Environments and datacenters that extend others are resolved once all of
+// the files are merged.
+func LoadVariables(paths ...string) (*Variables, error) {
+	return (&loader{strict: true}).loadFiles(paths)
+}
+
+// LoadVariablesUnchecked loads variables files like LoadVariables, without
+// validating them, for tools that report problems themselves
+func LoadVariablesUnchecked(paths ...string) (*Variables, error) {
+	return (&loader{}).loadFiles(paths)
+}
+
+// LoadVariablesFormat loads variables files like LoadVariables, reading them
+// in format rather than the one their extensions suggest. Included files are
+// still detected from their extensions.
+func LoadVariablesFormat(format string, paths ...string) (*Variables, error) {
+	return (&loader{strict: true, format: format}).loadFiles(paths)
+}
+
+// LoadVariablesUncheckedFormat loads variables files like
+// LoadVariablesUnchecked, in format like LoadVariablesFormat
+func LoadVariablesUncheckedFormat(format string, paths ...string) (*Variables, error) {
+	return (&loader{format: format}).loadFiles(paths)
+}
+
+// loader reads variables files, and definitions from Vault when it has a
+// Reader to read them with
+type loader struct {
+	ctx    context.Context
+	reader *Reader
+	strict bool
+	// format of the files loaded, detected from their extensions when empty
+	format string
+	// skipRemote leaves out definitions in Vault instead of reading them
+	skipRemote bool
+}
+
+func (l *loader) loadFiles(paths []string) (*Variables, error) {
+	data := &Variables{}
+	for _, path := range paths {
+		optionalPath, isOptional := strings.CutSuffix(path, "?")
+		if l.skipRemote && isRemote(optionalPath) {
+			continue
+		}
+		if isOptional {
+			path = optionalPath
+			if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) && !isRemote(path) {
+				continue
+			}
+		}
+		fileData, err := l.load(path, l.format, []string{})
+		if isOptional && errors.Is(err, ErrSecretNotFound) {
+			// An optional definition in Vault that doesn't exist
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		merged := MergeVariables(*data, *fileData)
+		merged.File = path
+		data = &merged
+	}
+	if err := data.resolveExtends(); err != nil {
+		return nil, fmt.Errorf("%s: %w", strings.Join(paths, ", "), err)
+	}
+	return data, nil
+}
+
+func (l *loader) load(path string, format string, stack []string) (*Variables, error) {
+	absPath := path
+	if !isRemote(path) {
+		var err error
+		absPath, err = filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if slices.Contains(stack, absPath) {
+		return nil, fmt.Errorf("include cycle: %s -> %s", strings.Join(stack, " -> "), absPath)
+	}
+	stack = append(stack, absPath)
+
+	raw, err := l.read(path)
+	if err != nil {
+		return nil, err
+	}
+	if format == "" {
+		format = fileFormat(path)
+	}
+	doc, err := parseDocument(raw, format)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s file %s: %w", strings.ToUpper(format), path, err)
+	}
+	if l.strict {
+		if problems := Validate(path, doc); len(problems) > 0 {
+			return nil, ValidationError(problems)
+		}
+	}
+	var data Variables
+	if len(doc.Content) > 0 {
+		if err := doc.Decode(&data); err != nil {
+			return nil, fmt.Errorf("unable to parse %s file %s: %w", strings.ToUpper(format), path, err)
+		}
+	}
+	if !isRemote(path) {
+		data.eachScope(func(_ string, s scope) {
+			// The scope shares its env_files list, so the paths change in place
+			for i, envFile := range s.EnvFiles {
+				s.EnvFiles[i] = includePath(path, envFile)
+			}
+		})
+	}
+	data.File = path
+	data.Origins = data.origins(path)
+
+	merged := Variables{}
+	for _, include := range data.Include {
+		if l.skipRemote && isRemote(includePath(path, include)) {
+			continue
+		}
+		included, err := l.load(includePath(path, include), "", stack)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		merged = MergeVariables(merged, *included)
+	}
+	merged = MergeVariables(merged, data)
+	merged.File = path
+	merged.Include = data.Include
+	return &merged, nil
+}
+
+// read gets the contents of a local file or a definition in Vault
+func (l *loader) read(path string) ([]byte, error) {
+	if isRemote(path) {
+		if l.reader == nil {
+			return nil, fmt.Errorf("unable to read %s: definitions in Vault can only be read when building an environment", path)
+		}
+		return l.reader.readDefinition(l.ctx, path)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read file %s: %w", path, err)
+	}
+	return raw, nil
+}
+
+// includePath finds an included file relative to the file including it.
+// Includes in a definition from Vault are other paths in Vault.
+func includePath(path string, include string) string {
+	if isRemote(include) {
+		return include
+	}
+	if isRemote(path) {
+		return remoteInclude(path, include)
+	}
+	if filepath.IsAbs(include) {
+		return include
+	}
+	return filepath.Join(filepath.Dir(path), include)
+}
+
+// MergeVariables layers top over base
+func MergeVariables(base Variables, top Variables) Variables {
+	merged := Variables{
+		Dimensions:   base.Dimensions,
+		scope:        mergeScopes(base.scope, top.scope),
+		Scopes:       mergeScopeTrees(base.Scopes, top.Scopes),
+		Environments: map[string]Environment{},
+		Origins:      mergeMaps(base.Origins, top.Origins),
+	}
+	if len(top.Dimensions) > 0 {
+		merged.Dimensions = top.Dimensions
+	}
+	merged.DefaultEnvironment, merged.DefaultDC = base.DefaultEnvironment, base.DefaultDC
+	if top.DefaultEnvironment != "" {
+		merged.DefaultEnvironment = top.DefaultEnvironment
+	}
+	if top.DefaultDC != "" {
+		merged.DefaultDC = top.DefaultDC
+	}
+	for name, env := range base.Environments {
+		merged.Environments[name] = env
+	}
+	for name, env := range top.Environments {
+		merged.Environments[name] = mergeEnvironments(merged.Environments[name], env)
+	}
+	if len(merged.Environments) == 0 {
+		merged.Environments = nil
+	}
+	return merged
+}
+
+func mergeEnvironments(base Environment, top Environment) Environment {
+	merged := Environment{
+		scope:   mergeScopes(base.scope, top.scope),
+		Extends: mergeExtends(base.Extends, top.Extends),
+		Scopes:  mergeScopeTrees(base.Scopes, top.Scopes),
+		Dcs:     map[string]DC{},
+	}
+	for name, dc := range base.Dcs {
+		merged.Dcs[name] = dc
+	}
+	for name, dc := range top.Dcs {
+		merged.Dcs[name] = DC{
+			scope:   mergeScopes(merged.Dcs[name].scope, dc.scope),
+			Extends: mergeExtends(merged.Dcs[name].Extends, dc.Extends),
+			Scopes:  mergeScopeTrees(merged.Dcs[name].Scopes, dc.Scopes),
+		}
+	}
+	if len(merged.Dcs) == 0 {
+		merged.Dcs = nil
+	}
+	return merged
+}
+
+// mergeExtends keeps the later extends list when both are set
+func mergeExtends(base Extends, top Extends) Extends {
+	if len(top) > 0 {
+		return top
+	}
+	return base
+}
+
+func mergeScopes(base scope, top scope) scope {
+	base = base.without(top.Unset)
+	merged := scope{
+		Vars:       mergeVars(base.Vars, top.Vars),
+		Separators: mergeMaps(base.Separators, top.Separators),
+		Encrypted:  mergeVars(base.Encrypted, top.Encrypted),
+		Secrets:    mergeVars(base.Secrets, top.Secrets),
+		ExecVars:   mergeVars(base.ExecVars, top.ExecVars),
+		Files:      mergeVars(base.Files, top.Files),
+		Required:   mergeNames(base.Required, top.Required),
+		FromEnv:    mergeVars(base.FromEnv, top.FromEnv),
+		EnvFiles:   concatLists(base.EnvFiles, top.EnvFiles),
+		FileVars:   concatLists(base.FileVars, top.FileVars),
+		KVSecrets:  concatLists(base.KVSecrets, top.KVSecrets),
+		KV1Secrets: concatLists(base.KV1Secrets, top.KV1Secrets),
+		Unset:      mergeNames(base.Unset, top.Unset),
+		varOrder:   mergeNames(base.varOrder, top.varOrder),
+		lists:      mergeVars(base.lists, top.lists),
+	}
+	// Plain and encrypted values share names, so a later one of either kind
+	// wins, and a later value that isn't a list replaces an earlier list
+	for _, v := range top.Vars {
+		merged.Encrypted = deleteVar(merged.Encrypted, v.Name)
+		if _, found := lookup(top.lists, v.Name); !found {
+			merged.lists = deleteVar(merged.lists, v.Name)
+		}
+	}
+	for _, v := range top.Encrypted {
+		merged.Vars = deleteVar(merged.Vars, v.Name)
+		merged.lists = deleteVar(merged.lists, v.Name)
+	}
+	if len(merged.Encrypted) == 0 {
+		merged.Encrypted = nil
+	}
+	if len(merged.Vars) == 0 {
+		merged.Vars = nil
+	}
+	return merged
+}
+
+// without drops the values a later file or child unsets at the same level,
+// which would otherwise be read after the unset
+func (s scope) without(names []string) scope {
+	if len(names) == 0 {
+		return s
+	}
+	s.Vars = slices.Clone(s.Vars)
+	s.lists = slices.Clone(s.lists)
+	s.Secrets = slices.Clone(s.Secrets)
+	s.Encrypted = slices.Clone(s.Encrypted)
+	s.ExecVars = slices.Clone(s.ExecVars)
+	s.Files = slices.Clone(s.Files)
+	s.FromEnv = slices.Clone(s.FromEnv)
+	s.Required = slices.Clone(s.Required)
+	for _, name := range names {
+		s.FromEnv = deleteVar(s.FromEnv, name)
+		s.Required = slices.DeleteFunc(s.Required, func(required string) bool { return required == name })
+		s.Vars = deleteVar(s.Vars, name)
+		s.lists = deleteVar(s.lists, name)
+		s.Secrets = deleteVar(s.Secrets, name)
+		s.Encrypted = deleteVar(s.Encrypted, name)
+		s.ExecVars = deleteVar(s.ExecVars, name)
+		s.Files = deleteVar(s.Files, name)
+	}
+	s.FileVars = withoutBlockVars(s.FileVars, func(b *FileVarBlock) *KVSecret { return &b.Vars }, names)
+	s.KVSecrets = withoutBlockVars(s.KVSecrets, func(b *KVSecretBlock) *KVSecret { return &b.Vars }, names)
+	s.KV1Secrets = withoutBlockVars(s.KV1Secrets, func(b *KV1SecretBlock) *KVSecret { return &b.Vars }, names)
+	return s
+}
+
+// withoutBlockVars drops names from each block, and blocks left with nothing
+// to read
+func withoutBlockVars[S ~[]B, B any](blocks S, vars func(*B) *KVSecret, names []string) S {
+	if blocks == nil {
+		return nil
+	}
+	kept := S{}
+	for _, block := range blocks {
+		blockVars := vars(&block)
+		hadVars := len(*blockVars) > 0
+		for _, name := range names {
+			*blockVars = deleteVar(*blockVars, name)
+		}
+		if hadVars && len(*blockVars) == 0 {
+			continue
+		}
+		kept = append(kept, block)
+	}
+	return kept
+}
+
+// mergeNames adds the names in top that aren't already in base
+func mergeNames(base []string, top []string) []string {
+	if base == nil && top == nil {
+		return nil
+	}
+	merged := slices.Clone(base)
+	for _, name := range top {
+		if !slices.Contains(merged, name) {
+			merged = append(merged, name)
+		}
+	}
+	return merged
+}
+
+func mergeMaps[M ~map[string]V, V any](base M, top M) M {
+	if base == nil && top == nil {
+		return nil
+	}
+	merged := M{}
+	maps.Copy(merged, base)
+	maps.Copy(merged, top)
+	return merged
+}
+
+func concatLists[S ~[]E, E any](base S, top S) S {
+	if base == nil && top == nil {
+		return nil
+	}
+	return append(slices.Clone(base), top...)
+}
+
+// origins records the file that each value in the variables came from, keyed
+// by where it appears, e.g. "environments.stage.vars.DB_HOST"
+func (v Variables) origins(file string) map[string]string {
+	origins := map[string]string{}
+	v.eachScope(func(path string, s scope) {
+		s.addOrigins(origins, path, file)
+	})
+	return origins
+}
+
+func (s scope) addOrigins(origins map[string]string, path string, file string) {
+	add := func(block string, name string) {
+		key := block + "." + name
+		if path != "" {
+			key = path + "." + key
+		}
+		origins[key] = file
+	}
+	for _, v := range s.Vars {
+		add("vars", v.Name)
+	}
+	for _, v := range s.Encrypted {
+		add("vars", v.Name)
+	}
+	for _, v := range s.Secrets {
+		add("secrets", v.Name)
+	}
+	for _, v := range s.ExecVars {
+		add("exec_vars", v.Name)
+	}
+	for _, v := range s.Files {
+		add("files", v.Name)
+	}
+	for _, name := range s.Required {
+		add("required", name)
+	}
+	for _, v := range s.FromEnv {
+		add("from_env", v.Name)
+	}
+	for _, envFile := range s.EnvFiles {
+		add("env_files", envFile)
+	}
+	for _, block := range s.FileVars {
+		for _, name := range varNames(block.Vars) {
+			add("file_vars", name)
+		}
+	}
+	for _, block := range s.KVSecrets {
+		for _, name := range varNames(block.Vars) {
+			add("kv_secrets", name)
+		}
+	}
+	for _, block := range s.KV1Secrets {
+		for _, name := range varNames(block.Vars) {
+			add("kv1_secrets", name)
+		}
+	}
+	for _, name := range s.Unset {
+		add("unset", name)
+	}
+}
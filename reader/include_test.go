@@ -0,0 +1,203 @@
+package reader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadVariables_Include(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"shared/base.yml": `
+vars:
+  FROM_BASE: "base"
+  OVERRIDDEN: "base"
+  WAS_PLAIN: "plain"
+kv_secrets:
+  - path: "secret/base"
+    vars:
+      BASE_SECRET: "value"
+environments:
+  stage:
+    vars:
+      ENVIRONMENT: "stage"
+    dcs:
+      east:
+        vars:
+          DC: "east"
+`,
+		"shared/envs.yml": `
+include:
+  - base.yml
+vars:
+  OVERRIDDEN: "envs"
+environments:
+  prod:
+    vars:
+      ENVIRONMENT: "prod"
+`,
+		"variables.yml": `
+include:
+  - shared/envs.yml
+vars:
+  OVERRIDDEN: "top"
+  WAS_PLAIN: !encrypted Y2lwaGVy
+kv_secrets:
+  - path: "secret/app"
+    vars:
+      APP_SECRET: "value"
+environments:
+  stage:
+    dcs:
+      east:
+        vars:
+          EXTRA: "top"
+      west:
+        vars:
+          DC: "west"
+`,
+	})
+
+	got, err := LoadVariables(filepath.Join(dir, "variables.yml"))
+	if err != nil {
+		t.Fatalf("LoadVariables() error = %v", err)
+	}
+
+	if want := (EnvVars{{"FROM_BASE", "base"}, {"OVERRIDDEN", "top"}}); !reflect.DeepEqual(got.Vars, want) {
+		t.Errorf("Vars = %v, want %v", got.Vars, want)
+	}
+	if want := (EncryptedVars{{"WAS_PLAIN", "Y2lwaGVy"}}); !reflect.DeepEqual(got.Encrypted, want) {
+		t.Errorf("Encrypted = %v, want %v", got.Encrypted, want)
+	}
+	if len(got.KVSecrets) != 2 || got.KVSecrets[0].Path != "secret/base" || got.KVSecrets[1].Path != "secret/app" {
+		t.Errorf("KVSecrets = %v, want included blocks first", got.KVSecrets)
+	}
+	if len(got.Environments) != 2 {
+		t.Errorf("Environments = %v, want stage and prod", got.Environments)
+	}
+	stage := got.Environments["stage"]
+	if want := (EnvVars{{"ENVIRONMENT", "stage"}}); !reflect.DeepEqual(stage.Vars, want) {
+		t.Errorf("stage Vars = %v, want %v", stage.Vars, want)
+	}
+	if want := (EnvVars{{"DC", "east"}, {"EXTRA", "top"}}); !reflect.DeepEqual(stage.Dcs["east"].Vars, want) {
+		t.Errorf("stage east Vars = %v, want %v", stage.Dcs["east"].Vars, want)
+	}
+	if want := (EnvVars{{"DC", "west"}}); !reflect.DeepEqual(stage.Dcs["west"].Vars, want) {
+		t.Errorf("stage west Vars = %v, want %v", stage.Dcs["west"].Vars, want)
+	}
+
+	origins := map[string]string{
+		"vars.FROM_BASE":                         "shared/base.yml",
+		"vars.OVERRIDDEN":                        "variables.yml",
+		"vars.WAS_PLAIN":                         "variables.yml",
+		"kv_secrets.BASE_SECRET":                 "shared/base.yml",
+		"kv_secrets.APP_SECRET":                  "variables.yml",
+		"environments.stage.vars.ENVIRONMENT":    "shared/base.yml",
+		"environments.stage.dcs.east.vars.DC":    "shared/base.yml",
+		"environments.stage.dcs.east.vars.EXTRA": "variables.yml",
+		"environments.prod.vars.ENVIRONMENT":     "shared/envs.yml",
+	}
+	for key, file := range origins {
+		if want := filepath.Join(dir, file); got.Origins[key] != want {
+			t.Errorf("Origins[%s] = %v, want %v", key, got.Origins[key], want)
+		}
+	}
+}
+
+func TestLoadVariables_IncludeErrors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.yml":       "include: [b.yml]\n",
+		"b.yml":       "include: [a.yml]\n",
+		"self.yml":    "include: [self.yml]\n",
+		"missing.yml": "include: [nope.yml]\n",
+		"bad.yml":     "include: [broken.yml]\n",
+		"broken.yml":  "vars: [\n",
+	})
+	tests := []struct {
+		file    string
+		wantErr string
+	}{
+		{file: "a.yml", wantErr: "include cycle"},
+		{file: "self.yml", wantErr: "include cycle"},
+		{file: "missing.yml", wantErr: "unable to read file"},
+		{file: "bad.yml", wantErr: "unable to parse YAML file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			_, err := LoadVariables(filepath.Join(dir, tt.file))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadVariables() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadVariables_DiamondInclude(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"common.yml": "vars:\n  COMMON: \"1\"\n",
+		"a.yml":      "include: [common.yml]\nvars:\n  A: \"a\"\n",
+		"b.yml":      "include: [common.yml]\nvars:\n  B: \"b\"\n",
+		"top.yml":    "include: [a.yml, b.yml]\n",
+	})
+	got, err := LoadVariables(filepath.Join(dir, "top.yml"))
+	if err != nil {
+		t.Fatalf("LoadVariables() error = %v", err)
+	}
+	if want := (EnvVars{{"COMMON", "1"}, {"A", "a"}, {"B", "b"}}); !reflect.DeepEqual(got.Vars, want) {
+		t.Errorf("Vars = %v, want %v", got.Vars, want)
+	}
+}
+
+func TestLoadVariables_MultipleFiles(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"base.yml":      "vars:\n  ORG: \"base\"\n  LOG_LEVEL: \"info\"\n",
+		"variables.yml": "vars:\n  LOG_LEVEL: \"debug\"\nenvironments:\n  dev:\n    vars:\n      ORG: \"dev\"\n",
+	})
+	base, repo := filepath.Join(dir, "base.yml"), filepath.Join(dir, "variables.yml")
+	got, err := LoadVariables(base, repo, filepath.Join(dir, "local.yml?"))
+	if err != nil {
+		t.Fatalf("LoadVariables() error = %v", err)
+	}
+	if want := (EnvVars{{"ORG", "base"}, {"LOG_LEVEL", "debug"}}); !reflect.DeepEqual(got.Vars, want) {
+		t.Errorf("Vars = %v, want %v", got.Vars, want)
+	}
+
+	reader, _ := NewReader(WithSkipVault(true))
+	out, err := reader.Read(context.Background(), got, "dev", "")
+	if err != nil {
+		t.Fatalf("Reader.Read() error = %v", err)
+	}
+	want := []Explanation{
+		{Key: "LOG_LEVEL", Definition: Definition{Origin: repo, Scope: "Global Variables"}},
+		{
+			Key:        "ORG",
+			Definition: Definition{Origin: repo, Scope: "Environment: dev"},
+			Overrides:  []Definition{{Origin: base, Scope: "Global Variables"}},
+		},
+	}
+	if got := out.Explain(); !reflect.DeepEqual(got, want) {
+		t.Errorf("OutputList.Explain() = %v, want %v", got, want)
+	}
+
+	if _, err := LoadVariables(base, filepath.Join(dir, "local.yml")); err == nil {
+		t.Errorf("LoadVariables() with a missing required file should fail")
+	}
+}
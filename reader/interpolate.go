@@ -0,0 +1,159 @@
+package reader
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// WithInterpolation expands ${VAR} references in values once all scopes are read
+func WithInterpolation(enabled bool) ReaderOptFunc {
+	return func(r *Reader) {
+		r.interpolate = enabled
+	}
+}
+
+// WithHostEnv lets ${VAR} references fall back to the caller's environment
+func WithHostEnv(enabled bool) ReaderOptFunc {
+	return func(r *Reader) {
+		r.hostEnv = enabled
+	}
+}
+
+// WithCommandLine adds values given on the command line, such as from
+// --env-file, after those of the scopes. They take precedence over every
+// scope and are interpolated along with the rest.
+func WithCommandLine(values OutputList) ReaderOptFunc {
+	return func(r *Reader) {
+		r.commandLine = values
+	}
+}
+
+// interpolation_regexp matches ${VAR} references and the $${ escape
+var interpolation_regexp = regexp.MustCompile(`\$\$\{|\$\{([_A-Za-z][A-Za-z0-9_]*)\}`)
+
+type interpolator struct {
+	list      OutputList
+	lastDef   map[string]int
+	lookupEnv func(string) (string, bool)
+	resolved  map[int]string
+	secret    map[int]bool
+	visiting  map[int]bool
+	stack     []string
+	undefined []string
+}
+
+// Interpolate expands ${VAR} references in non-secret values against the
+// final value of each variable. A variable referring to itself gets its
+// previous definition, so `PATH: "${PATH}:/opt/bin"` extends an earlier value.
+// Values built from a secret are secrets too. References that aren't defined
+// in the list are looked up with lookupEnv when it is set. Write $${ for a
+// literal ${.
+func (o OutputList) Interpolate(lookupEnv func(string) (string, bool)) (OutputList, error) {
+	in := &interpolator{
+		list:      o,
+		lastDef:   map[string]int{},
+		lookupEnv: lookupEnv,
+		resolved:  map[int]string{},
+		secret:    map[int]bool{},
+		visiting:  map[int]bool{},
+	}
+	for i, out := range o {
+		switch {
+		case out.Key == "":
+		case out.Unset:
+			// A variable unset by a later scope isn't defined
+			delete(in.lastDef, out.Key)
+		default:
+			in.lastDef[out.Key] = i
+		}
+	}
+
+	output := make(OutputList, len(o))
+	for i, out := range o {
+		if out.Key != "" && !out.Unset {
+			val, err := in.resolve(i)
+			if err != nil {
+				return nil, err
+			}
+			out.Value = val
+			out.Secret = out.Secret || in.secret[i]
+		}
+		output[i] = out
+	}
+	if len(in.undefined) > 0 {
+		return nil, fmt.Errorf("undefined variable references: %s", strings.Join(in.undefined, ", "))
+	}
+	return output, nil
+}
+
+// resolve returns the expanded value of the output at index i
+func (in *interpolator) resolve(i int) (string, error) {
+	if val, done := in.resolved[i]; done {
+		return val, nil
+	}
+	out := in.list[i]
+	if out.Secret {
+		return out.Value, nil
+	}
+	if in.visiting[i] {
+		return "", fmt.Errorf("interpolation cycle: %s -> %s", strings.Join(in.stack, " -> "), out.Key)
+	}
+	in.visiting[i] = true
+	in.stack = append(in.stack, out.Key)
+	defer func() {
+		in.visiting[i] = false
+		in.stack = in.stack[:len(in.stack)-1]
+	}()
+
+	var resolveErr error
+	val := interpolation_regexp.ReplaceAllStringFunc(out.Value, func(match string) string {
+		if match == "$${" {
+			return "${"
+		}
+		if resolveErr != nil {
+			return ""
+		}
+		name := match[2 : len(match)-1]
+		target, found := in.definition(name, i)
+		if !found {
+			if in.lookupEnv != nil {
+				if envVal, isSet := in.lookupEnv(name); isSet {
+					return envVal
+				}
+			}
+			in.undefined = append(in.undefined, fmt.Sprintf("${%s} in %s", name, out.Key))
+			return ""
+		}
+		refVal, err := in.resolve(target)
+		if err != nil {
+			resolveErr = err
+			return ""
+		}
+		if in.list[target].Secret || in.secret[target] {
+			in.secret[i] = true
+		}
+		return refVal
+	})
+	if resolveErr != nil {
+		return "", resolveErr
+	}
+	in.resolved[i] = val
+	return val, nil
+}
+
+// definition finds the output a reference from index i points to: the final
+// definition, or the previous one when a variable refers to itself. There's
+// none once the variable is unset.
+func (in *interpolator) definition(name string, from int) (int, bool) {
+	if in.list[from].Key != name {
+		idx, found := in.lastDef[name]
+		return idx, found
+	}
+	for j := from - 1; j >= 0; j-- {
+		if in.list[j].Key == name {
+			return j, !in.list[j].Unset
+		}
+	}
+	return 0, false
+}
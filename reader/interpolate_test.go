@@ -0,0 +1,266 @@
+package reader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/vault-client-go"
+)
+
+func TestOutputList_Interpolate(t *testing.T) {
+	hostEnv := func(name string) (string, bool) {
+		env := map[string]string{"HOME": "/home/me", "PATH": "/usr/bin"}
+		val, found := env[name]
+		return val, found
+	}
+
+	tests := []struct {
+		name      string
+		in        OutputList
+		lookupEnv func(string) (string, bool)
+		want      OutputList
+		wantErr   string
+	}{
+		{
+			name: "References Later Scopes And Secrets",
+			in: OutputList{
+				{Comment: "Global Variables"},
+				{Key: "DATABASE_URL", Value: "postgres://${DB_USER}:${DB_PASS}@${DB_HOST}/app"},
+				{Key: "DB_USER", Value: "app"},
+				{Comment: "Environment: dev"},
+				{Key: "DB_PASS", Value: "p@${ss", Secret: true},
+				{Key: "DB_HOST", Value: "${DB_NAME}.internal"},
+				{Key: "DB_NAME", Value: "dev-db"},
+			},
+			want: OutputList{
+				{Comment: "Global Variables"},
+				{Key: "DATABASE_URL", Value: "postgres://app:p@${ss@dev-db.internal/app", Secret: true},
+				{Key: "DB_USER", Value: "app"},
+				{Comment: "Environment: dev"},
+				{Key: "DB_PASS", Value: "p@${ss", Secret: true},
+				{Key: "DB_HOST", Value: "dev-db.internal"},
+				{Key: "DB_NAME", Value: "dev-db"},
+			},
+		},
+		{
+			name: "Last Definition Wins",
+			in: OutputList{
+				{Key: "URL", Value: "http://${HOST}"},
+				{Key: "HOST", Value: "global"},
+				{Key: "HOST", Value: "dc"},
+			},
+			want: OutputList{
+				{Key: "URL", Value: "http://dc"},
+				{Key: "HOST", Value: "global"},
+				{Key: "HOST", Value: "dc"},
+			},
+		},
+		{
+			name: "Escapes",
+			in: OutputList{
+				{Key: "A", Value: "a"},
+				{Key: "LITERAL", Value: "$${A} and $A and ${A}"},
+			},
+			want: OutputList{
+				{Key: "A", Value: "a"},
+				{Key: "LITERAL", Value: "${A} and $A and a"},
+			},
+		},
+		{
+			name: "Self Reference Uses Previous Definition",
+			in: OutputList{
+				{Key: "OPTS", Value: "-a"},
+				{Key: "OPTS", Value: "${OPTS} -b"},
+				{Key: "OPTS", Value: "${OPTS} -c"},
+			},
+			want: OutputList{
+				{Key: "OPTS", Value: "-a"},
+				{Key: "OPTS", Value: "-a -b"},
+				{Key: "OPTS", Value: "-a -b -c"},
+			},
+		},
+		{
+			name:      "Host Environment",
+			lookupEnv: hostEnv,
+			in: OutputList{
+				{Key: "PATH", Value: "${HOME}/bin:${PATH}"},
+			},
+			want: OutputList{
+				{Key: "PATH", Value: "/home/me/bin:/usr/bin"},
+			},
+		},
+		{
+			name: "Cycle",
+			in: OutputList{
+				{Key: "A", Value: "${B}"},
+				{Key: "B", Value: "${C}"},
+				{Key: "C", Value: "${A}"},
+			},
+			wantErr: "interpolation cycle: A -> B -> C -> A",
+		},
+		{
+			name: "Undefined",
+			in: OutputList{
+				{Key: "A", Value: "${NOPE}"},
+				{Key: "B", Value: "${HOME} ${ALSO_NOPE}"},
+			},
+			wantErr: "undefined variable references: ${NOPE} in A, ${HOME} in B, ${ALSO_NOPE} in B",
+		},
+		{
+			name: "Unset Is Undefined",
+			in: OutputList{
+				{Key: "PROXY", Value: "proxy:8080"},
+				{Key: "PROXY", Unset: true},
+				{Key: "A", Value: "${PROXY}"},
+				{Key: "OPTS", Value: "-a"},
+				{Key: "OPTS", Unset: true},
+				{Key: "OPTS", Value: "${OPTS} -b"},
+			},
+			wantErr: "undefined variable references: ${PROXY} in A, ${OPTS} in OPTS",
+		},
+		{
+			name:      "Unset Uses Host Environment",
+			lookupEnv: hostEnv,
+			in: OutputList{
+				{Key: "PATH", Value: "/opt/bin"},
+				{Key: "PATH", Unset: true},
+				{Key: "TOOLS", Value: "${PATH}:/tools"},
+			},
+			want: OutputList{
+				{Key: "PATH", Value: "/opt/bin"},
+				{Key: "PATH", Unset: true},
+				{Key: "TOOLS", Value: "/usr/bin:/tools"},
+			},
+		},
+		{
+			name:      "Undefined With Host Environment",
+			lookupEnv: hostEnv,
+			in: OutputList{
+				{Key: "A", Value: "${HOME}/${NOPE}"},
+			},
+			wantErr: "undefined variable references: ${NOPE} in A",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.in.Interpolate(tt.lookupEnv)
+			if err != nil {
+				if tt.wantErr == "" || err.Error() != tt.wantErr {
+					t.Errorf("OutputList.Interpolate() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				t.Errorf("OutputList.Interpolate() error = nil, wantErr %v", tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("OutputList.Interpolate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReader_ReadInterpolation(t *testing.T) {
+	input := &Variables{
+		scope: scope{
+			Vars: EnvVars{{"URL", "https://${HOST}/"}},
+		},
+		Environments: map[string]Environment{
+			"dev": {
+				scope: scope{
+					Vars: EnvVars{{"HOST", "dev.example.com"}},
+				},
+			},
+		},
+	}
+	want := OutputList{
+		{Comment: "Global Variables"},
+		{Key: "URL", Value: "https://dev.example.com/"},
+		{Comment: "Environment: dev"},
+		{Key: "HOST", Value: "dev.example.com"},
+	}
+
+	reader, _ := NewReader(WithSkipVault(true), WithInterpolation(true))
+	got, err := reader.Read(context.Background(), input, "dev", "")
+	if err != nil {
+		t.Fatalf("Reader.Read() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Reader.Read() = %v, want %v", got, want)
+	}
+
+	// Without interpolation values are left alone
+	reader, _ = NewReader(WithSkipVault(true))
+	got, _ = reader.Read(context.Background(), input, "dev", "")
+	if got[1].Value != "https://${HOST}/" {
+		t.Errorf("Reader.Read() without interpolation = %v", got[1].Value)
+	}
+}
+
+func TestReader_ReadInterpolationCommandLine(t *testing.T) {
+	input := &Variables{
+		scope: scope{
+			Vars: EnvVars{{"URL", "https://${HOST}/"}},
+		},
+	}
+	commandLine := OutputList{
+		{Key: "HOST", Value: "local.example.com", Origin: "-u"},
+		{Key: "HOME_URL", Value: "${URL}home", Origin: "-u"},
+	}
+	want := OutputList{
+		{Comment: "Global Variables"},
+		{Key: "URL", Value: "https://local.example.com/"},
+		{Comment: "Command Line"},
+		{Key: "HOST", Value: "local.example.com", Origin: "-u"},
+		{Key: "HOME_URL", Value: "https://local.example.com/home", Origin: "-u"},
+	}
+
+	reader, _ := NewReader(WithSkipVault(true), WithInterpolation(true), WithCommandLine(commandLine))
+	got, err := reader.Read(context.Background(), input, "", "")
+	if err != nil {
+		t.Fatalf("Reader.Read() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Reader.Read() = %v, want %v", got, want)
+	}
+}
+
+func TestReader_ReadInterpolationSecretRefs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/secret/data/db":
+			w.Write([]byte(`{"data":{"data":{"password":"hunter2"},"metadata":{"version":1}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer server.Close()
+
+	client, _ := vault.New(vault.WithAddress(server.URL))
+	reader := &Reader{client: client, interpolate: true}
+
+	input := &Variables{
+		scope: scope{
+			Vars: EnvVars{
+				{"DB_URL", "postgres://app:${DB_PASS}@db/app"},
+				{"DB_PASS", "vault://secret/db#password"},
+			},
+		},
+	}
+	want := OutputList{
+		{Comment: "Global Variables"},
+		{Key: "DB_URL", Value: "postgres://app:hunter2@db/app", Secret: true},
+		{Key: "DB_PASS", Value: "hunter2", Comment: "References: secret/db#password", Secret: true},
+	}
+	got, err := reader.Read(context.Background(), input, "", "")
+	if err != nil {
+		t.Fatalf("Reader.Read() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Reader.Read() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,388 @@
+package reader
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Severity is how serious a lint finding is
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// LintRule is a check made by Lint
+type LintRule struct {
+	ID          string
+	Name        string
+	Severity    Severity
+	Description string
+	check       func(v *Variables, report func(path string, message string))
+}
+
+// Finding is a problem found by a lint rule. Path is where in the variables
+// it was found, e.g. "environments.stage.vars.DB_PASSWORD".
+type Finding struct {
+	RuleID   string   `json:"rule_id"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+	File     string   `json:"file,omitempty"`
+	Path     string   `json:"path,omitempty"`
+}
+
+func (f Finding) String() string {
+	location := ""
+	for _, part := range []string{f.File, f.Path} {
+		if part != "" {
+			location += part + ": "
+		}
+	}
+	return fmt.Sprintf("%s%s %s: %s", location, f.Severity, f.RuleID, f.Message)
+}
+
+// LintRules are the checks made by Lint, by ID
+var LintRules = []LintRule{
+	{
+		ID:          "BE001",
+		Name:        "duplicate-variable",
+		Severity:    SeverityWarning,
+		Description: "A variable is set by more than one source in the same scope, so which value wins depends on the order sources are read, or is set again by a narrower scope",
+		check:       lintDuplicateVariables,
+	},
+	{
+		ID:          "BE002",
+		Name:        "missing-datacenter",
+		Severity:    SeverityWarning,
+		Description: "A datacenter is defined in some environments but not others that have datacenters",
+		check:       lintMissingDatacenters,
+	},
+	{
+		ID:          "BE003",
+		Name:        "invalid-name",
+		Severity:    SeverityError,
+		Description: "A variable name isn't valid in a shell, so it would be left out of the output",
+		check:       lintInvalidNames,
+	},
+	{
+		ID:          "BE004",
+		Name:        "plaintext-secret",
+		Severity:    SeverityWarning,
+		Description: "A plain value looks like a password or key, which belongs in Vault or an !encrypted value",
+		check:       lintPlaintextSecrets,
+	},
+}
+
+// Lint checks variables for likely mistakes, skipping the rules with the
+// given IDs or names. Findings are in rule order, then by path.
+func Lint(v *Variables, disabled []string) []Finding {
+	findings := []Finding{}
+	for _, rule := range LintRules {
+		if slices.Contains(disabled, rule.ID) || slices.Contains(disabled, rule.Name) {
+			continue
+		}
+		ruleFindings := []Finding{}
+		rule.check(v, func(path string, message string) {
+			ruleFindings = append(ruleFindings, Finding{
+				RuleID:   rule.ID,
+				Severity: rule.Severity,
+				Message:  message,
+				File:     v.originOf(path),
+				Path:     path,
+			})
+		})
+		slices.SortStableFunc(ruleFindings, func(a, b Finding) int {
+			return strings.Compare(a.Path, b.Path)
+		})
+		findings = append(findings, ruleFindings...)
+	}
+	return findings
+}
+
+// originOf finds the file that set a value, or the first file that sets
+// anything within the scope at path
+func (v *Variables) originOf(path string) string {
+	if origin, found := v.Origins[path]; found {
+		return origin
+	}
+	for _, key := range sortedKeys(v.Origins) {
+		if strings.HasPrefix(key, path+".") {
+			return v.Origins[key]
+		}
+	}
+	return v.File
+}
+
+// eachScope calls fn for the global scope, every environment and datacenter,
+// and every scope nested in them
+func (v *Variables) eachScope(fn func(path string, s scope)) {
+	v.tree().walk(nil, func(node scopeNode, _ []scopeNode) {
+		fn(node.path, node.scope)
+	})
+}
+
+// sources lists each variable a scope sets, e.g. "DB_HOST" from "vars", in
+// the order they're read
+func (s scope) sources() [][2]string {
+	sources := [][2]string{}
+	add := func(block string, names []string) {
+		for _, name := range names {
+			sources = append(sources, [2]string{block, name})
+		}
+	}
+	add("vars", varNames(s.Vars))
+	add("vars", varNames(s.Encrypted))
+	for _, block := range s.FileVars {
+		add("file_vars", varNames(block.Vars))
+	}
+	add("exec_vars", varNames(s.ExecVars))
+	add("files", varNames(s.Files))
+	add("required", s.Required)
+	add("from_env", varNames(s.FromEnv))
+	for _, block := range s.KVSecrets {
+		add("kv_secrets", varNames(block.Vars))
+	}
+	for _, block := range s.KV1Secrets {
+		add("kv1_secrets", varNames(block.Vars))
+	}
+	add("secrets", varNames(s.Secrets))
+	return sources
+}
+
+// lastSource finds the block a scope last sets a name from. Required names
+// aren't values, so they don't count.
+func (s scope) lastSource(name string) (string, bool) {
+	sources := s.sources()
+	for i := len(sources) - 1; i >= 0; i-- {
+		if sources[i][1] == name && sources[i][0] != "required" {
+			return sources[i][0], true
+		}
+	}
+	return "", false
+}
+
+// describe names the scope in findings
+func (n scopeNode) describe() string {
+	if n.path == "" {
+		return "the global scope"
+	}
+	return n.path
+}
+
+func scopePath(path string, block string, name string) string {
+	if path == "" {
+		return block + "." + name
+	}
+	return path + "." + block + "." + name
+}
+
+func lintDuplicateVariables(v *Variables, report func(string, string)) {
+	v.tree().walk(nil, func(node scopeNode, ancestors []scopeNode) {
+		blocks := map[string][]string{}
+		for _, source := range node.scope.sources() {
+			blocks[source[1]] = append(blocks[source[1]], source[0])
+		}
+		for _, name := range sortedKeys(blocks) {
+			earlier, last := blocks[name][:len(blocks[name])-1], blocks[name][len(blocks[name])-1]
+			path := scopePath(node.path, last, name)
+			if len(earlier) > 0 {
+				report(path, fmt.Sprintf("%s is set by %s and %s; the value from %s wins", name, strings.Join(earlier, ", "), last, last))
+			}
+
+			// Values inherited through extends are reported where they're set
+			if _, found := v.Inherited[path]; found || last == "required" {
+				continue
+			}
+			// The nearest broader scope setting it is the one replaced
+			for i := len(ancestors) - 1; i >= 0; i-- {
+				if block, found := ancestors[i].scope.lastSource(name); found {
+					report(path, fmt.Sprintf("%s is set by %s in %s and by %s in %s; the value from %s wins", name, block, ancestors[i].describe(), last, node.describe(), node.describe()))
+					break
+				}
+			}
+		}
+	})
+}
+
+func lintMissingDatacenters(v *Variables, report func(string, string)) {
+	dcEnvs := map[string][]string{}
+	for envName, env := range v.Environments {
+		for dcName := range env.Dcs {
+			dcEnvs[dcName] = append(dcEnvs[dcName], envName)
+		}
+	}
+	for _, envName := range sortedKeys(v.Environments) {
+		env := v.Environments[envName]
+		if len(env.Dcs) == 0 {
+			continue
+		}
+		for _, dcName := range sortedKeys(dcEnvs) {
+			if _, found := env.Dcs[dcName]; !found {
+				others := dcEnvs[dcName]
+				slices.Sort(others)
+				report(fmt.Sprintf("environments.%s.dcs", envName), fmt.Sprintf("datacenter %s is defined in %s but not in %s", dcName, strings.Join(others, ", "), envName))
+			}
+		}
+	}
+}
+
+func lintInvalidNames(v *Variables, report func(string, string)) {
+	v.eachScope(func(path string, s scope) {
+		for _, source := range s.sources() {
+			if !shellvar_regexp.MatchString(source[1]) {
+				report(scopePath(path, source[0], source[1]), fmt.Sprintf("%q isn't a valid shell variable name and would be left out", source[1]))
+			}
+		}
+	})
+}
+
+// secret_name_regexp matches variable names that usually hold secrets
+var secret_name_regexp = regexp.MustCompile(`(?i)(passw(or)?d|secret|token|api_?key|private_?key|credential)`)
+
+func lintPlaintextSecrets(v *Variables, report func(string, string)) {
+	v.eachScope(func(path string, s scope) {
+		for _, v := range s.Vars {
+			name, value := v.Name, v.Value
+			if reason := secretReason(name, value); reason != "" {
+				report(scopePath(path, "vars", name), fmt.Sprintf("%s %s; use Vault or an !encrypted value", name, reason))
+			}
+		}
+	})
+}
+
+// secretReason explains why a plain value looks like a secret, if it does.
+// Values that refer to something else, such as ${VAR}, templates and
+// secret references, are fine. Other URLs only count if they hold a password.
+func secretReason(name string, value string) string {
+	if value == "" || strings.Contains(value, "${") || strings.Contains(value, "{{") {
+		return ""
+	}
+	if refs, _, err := ParseSecretRefs(value); len(refs) > 0 || err != nil {
+		return ""
+	}
+	if strings.Contains(value, "://") {
+		for _, field := range strings.Fields(value) {
+			if u, err := url.Parse(field); err == nil && u.User != nil {
+				if password, _ := u.User.Password(); password != "" {
+					return "has a password in a URL"
+				}
+			}
+		}
+		return ""
+	}
+	if secret_name_regexp.MatchString(name) {
+		return "looks like it holds a password or key"
+	}
+	if len(value) >= 20 && !strings.ContainsAny(value, " \t") && entropy(value) >= 4 {
+		return "has a high-entropy value"
+	}
+	return ""
+}
+
+// entropy is the Shannon entropy of a string, in bits per character
+func entropy(value string) float64 {
+	counts := map[rune]int{}
+	total := 0
+	for _, r := range value {
+		counts[r]++
+		total++
+	}
+	bits := 0.0
+	for _, count := range counts {
+		p := float64(count) / float64(total)
+		bits -= p * math.Log2(p)
+	}
+	return bits
+}
+
+// LintJSON renders findings as a JSON array
+func LintJSON(findings []Finding) ([]byte, error) {
+	return json.MarshalIndent(findings, "", "  ")
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifRule struct {
+	ID                   string            `json:"id"`
+	Name                 string            `json:"name"`
+	ShortDescription     sarifMessage      `json:"shortDescription"`
+	DefaultConfiguration map[string]string `json:"defaultConfiguration"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []map[string]string    `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation map[string]string `json:"artifactLocation"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+// LintSARIF renders findings as a SARIF 2.1.0 log, for code scanning tools
+func LintSARIF(findings []Finding, version string) ([]byte, error) {
+	rules := []sarifRule{}
+	for _, rule := range LintRules {
+		rules = append(rules, sarifRule{
+			ID:                   rule.ID,
+			Name:                 rule.Name,
+			ShortDescription:     sarifMessage{Text: rule.Description},
+			DefaultConfiguration: map[string]string{"level": string(rule.Severity)},
+		})
+	}
+
+	results := []sarifResult{}
+	for _, finding := range findings {
+		result := sarifResult{
+			RuleID:  finding.RuleID,
+			Level:   string(finding.Severity),
+			Message: sarifMessage{Text: finding.Message},
+		}
+		location := sarifLocation{}
+		if finding.File != "" {
+			location.PhysicalLocation = &sarifPhysicalLocation{
+				ArtifactLocation: map[string]string{"uri": finding.File},
+			}
+		}
+		if finding.Path != "" {
+			location.LogicalLocations = []map[string]string{{"fullyQualifiedName": finding.Path}}
+		}
+		if location.PhysicalLocation != nil || location.LogicalLocations != nil {
+			result.Locations = []sarifLocation{location}
+		}
+		results = append(results, result)
+	}
+
+	log := map[string]interface{}{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs": []interface{}{
+			map[string]interface{}{
+				"tool": map[string]interface{}{
+					"driver": map[string]interface{}{
+						"name":           "buildenv",
+						"version":        version,
+						"informationUri": "https://github.com/Comcast/Buildenv-Tool",
+						"rules":          rules,
+					},
+				},
+				"results": results,
+			},
+		},
+	}
+	return json.MarshalIndent(log, "", "  ")
+}
@@ -0,0 +1,199 @@
+package reader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrSecretNotFound is returned when a Vault path doesn't exist
+var ErrSecretNotFound = errors.New("secret does not exist")
+
+// SecretOptions let a secret be missing from Vault. An optional secret is
+// left out, and one with a default gets the default instead. Options that
+// aren't set are taken from the block.
+type SecretOptions struct {
+	Optional *bool   `yaml:"optional,omitempty" json:",omitempty"`
+	Default  *string `yaml:"default,omitempty" json:",omitempty"`
+}
+
+// with layers the options of a single variable over those of its block, so
+// a variable can set optional: false in an optional block
+func (o SecretOptions) with(top SecretOptions) SecretOptions {
+	merged := o
+	if top.Optional != nil {
+		merged.Optional = top.Optional
+	}
+	if top.Default != nil {
+		merged.Default = top.Default
+	}
+	return merged
+}
+
+func (o SecretOptions) allowsMissing() bool {
+	return o.Optional != nil && *o.Optional || o.Default != nil
+}
+
+// MissingSecret is an optional secret that wasn't found in Vault
+type MissingSecret struct {
+	Key         string
+	Path        string
+	VaultKey    string
+	UsedDefault bool
+}
+
+// WithWarnings sets where warnings about missing optional secrets are
+// written. The default is stderr.
+func WithWarnings(w io.Writer) ReaderOptFunc {
+	return func(r *Reader) {
+		r.warnings = w
+	}
+}
+
+// Missing lists the optional secrets that weren't found, in the order they
+// were read
+func (r *Reader) Missing() []MissingSecret {
+	return r.missing
+}
+
+func (r *Reader) warnMissing(missing MissingSecret) {
+	r.missing = append(r.missing, missing)
+	w := r.warnings
+	if w == nil {
+		w = os.Stderr
+	}
+	action := "skipping it"
+	if missing.UsedDefault {
+		action = "using the default"
+	}
+	fmt.Fprintf(w, "Warning: optional secret %s not found (Path: %s, Key: %s), %s\n", missing.Key, missing.Path, missing.VaultKey, action)
+}
+
+// readBlock reads the variables of a kv_secrets or kv1_secrets block. A
+// missing path or key is only an error for variables that aren't optional.
+// List values are joined with the variable's separator.
+func (r *Reader) readBlock(ctx context.Context, path string, vars KVSecret, kv1 bool, options SecretOptions, varOptions map[string]SecretOptions, separators Separators) (OutputList, error) {
+	data, err := r.readKV(ctx, path, kv1)
+	if err != nil {
+		if !errors.Is(err, ErrSecretNotFound) {
+			return nil, err
+		}
+		for _, v := range vars {
+			if !options.with(varOptions[v.Name]).allowsMissing() {
+				return nil, err
+			}
+		}
+	}
+
+	output := OutputList{}
+	for _, v := range vars {
+		varName, varKey := v.Name, v.Value
+		comment := fmt.Sprintf("Path: %s, Key: %s", path, varKey)
+		value, hasValue := data[varKey]
+		if !hasValue {
+			varOpts := options.with(varOptions[varName])
+			if !varOpts.allowsMissing() {
+				return nil, fmt.Errorf("key %s not found in path %s", varKey, path)
+			}
+			r.warnMissing(MissingSecret{Key: varName, Path: path, VaultKey: varKey, UsedDefault: varOpts.Default != nil})
+			if varOpts.Default != nil {
+				output = append(output, Output{
+					Key:     varName,
+					Value:   *varOpts.Default,
+					Comment: comment + ", Default",
+					// A default stands in for a secret, so it's redacted like one
+					Secret: true,
+				})
+			}
+			continue
+		}
+		output = append(output, Output{
+			Key:     varName,
+			Value:   secretValue(value, separators.get(varName)),
+			Comment: comment,
+			Secret:  true,
+		})
+	}
+	return output, nil
+}
+
+// secretBlock is how kv_secrets and kv1_secrets blocks are written. Each of
+// the vars is either a key, or a mapping of the key and its own options.
+type secretBlock struct {
+	Path          string `yaml:"path"`
+	SecretOptions `yaml:",inline"`
+	Vars          yaml.Node `yaml:"vars"`
+}
+
+// decode reads the block's vars, returning the options given to single
+// variables, if any
+func (b secretBlock) decode() (KVSecret, map[string]SecretOptions, error) {
+	if b.Vars.Kind == 0 {
+		return nil, nil, nil
+	}
+	if b.Vars.Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("line %d: vars must be a mapping", b.Vars.Line)
+	}
+	vars := KVSecret{}
+	var varOptions map[string]SecretOptions
+	for i := 0; i+1 < len(b.Vars.Content); i += 2 {
+		key, value := b.Vars.Content[i], b.Vars.Content[i+1]
+		if _, found := lookup(vars, key.Value); found {
+			return nil, nil, fmt.Errorf("line %d: vars %q is defined more than once", key.Line, key.Value)
+		}
+		if value.Kind != yaml.MappingNode {
+			var vaultKey string
+			if err := value.Decode(&vaultKey); err != nil {
+				return nil, nil, err
+			}
+			vars = append(vars, Var{Name: key.Value, Value: vaultKey})
+			continue
+		}
+		var secretVar struct {
+			Key           string `yaml:"key"`
+			SecretOptions `yaml:",inline"`
+		}
+		if err := value.Decode(&secretVar); err != nil {
+			return nil, nil, err
+		}
+		if secretVar.Key == "" {
+			return nil, nil, fmt.Errorf("line %d: %s needs a key", value.Line, key.Value)
+		}
+		vars = append(vars, Var{Name: key.Value, Value: secretVar.Key})
+		if varOptions == nil {
+			varOptions = map[string]SecretOptions{}
+		}
+		varOptions[key.Value] = secretVar.SecretOptions
+	}
+	return vars, varOptions, nil
+}
+
+func (s *KVSecretBlock) UnmarshalYAML(node *yaml.Node) error {
+	var block secretBlock
+	if err := node.Decode(&block); err != nil {
+		return err
+	}
+	vars, varOptions, err := block.decode()
+	if err != nil {
+		return err
+	}
+	*s = KVSecretBlock{Path: block.Path, Vars: vars, SecretOptions: block.SecretOptions, VarOptions: varOptions}
+	return nil
+}
+
+func (s *KV1SecretBlock) UnmarshalYAML(node *yaml.Node) error {
+	var block secretBlock
+	if err := node.Decode(&block); err != nil {
+		return err
+	}
+	vars, varOptions, err := block.decode()
+	if err != nil {
+		return err
+	}
+	*s = KV1SecretBlock{Path: block.Path, Vars: vars, SecretOptions: block.SecretOptions, VarOptions: varOptions}
+	return nil
+}
@@ -0,0 +1,160 @@
+package reader
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/vault-client-go"
+	"gopkg.in/yaml.v3"
+)
+
+func TestKVSecretBlock_UnmarshalOptional(t *testing.T) {
+	input := `
+path: "kv2/test"
+optional: true
+vars:
+  ONE: "one"
+  TWO:
+    key: "two"
+    default: ""
+  THREE:
+    key: "three"
+    optional: false
+`
+	var got KVSecretBlock
+	if err := yaml.Unmarshal([]byte(input), &got); err != nil {
+		t.Fatalf("yaml.Unmarshal() error = %v", err)
+	}
+	empty, yes, no := "", true, false
+	want := KVSecretBlock{
+		Path:          "kv2/test",
+		Vars:          KVSecret{{"ONE", "one"}, {"TWO", "two"}, {"THREE", "three"}},
+		SecretOptions: SecretOptions{Optional: &yes},
+		VarOptions:    map[string]SecretOptions{"TWO": {Default: &empty}, "THREE": {Optional: &no}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("KVSecretBlock.UnmarshalYAML() = %+v, want %+v", got, want)
+	}
+
+	if err := yaml.Unmarshal([]byte("path: kv2/test\nvars:\n  ONE:\n    optional: true\n"), &got); err == nil {
+		t.Errorf("KVSecretBlock.UnmarshalYAML() without a key should fail")
+	}
+}
+
+func TestKVSecretBlock_GetOutputOptional(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/kv2/data/test" {
+			w.Write([]byte(`{"data":{"data":{"one":"1"},"metadata":{"version":1}}}`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"errors":[]}`))
+	}))
+	defer server.Close()
+
+	fallback, yes, no := "fallback", true, false
+	tests := []struct {
+		name        string
+		block       KVSecretBlock
+		want        OutputList
+		wantMissing []MissingSecret
+		wantErr     bool
+	}{
+		{
+			name: "Missing path",
+			block: KVSecretBlock{
+				Path: "kv2/nope",
+				Vars: KVSecret{{"ONE", "one"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Optional missing path",
+			block: KVSecretBlock{
+				Path:          "kv2/nope",
+				Vars:          KVSecret{{"ONE", "one"}, {"TWO", "two"}},
+				SecretOptions: SecretOptions{Optional: &yes},
+				VarOptions:    map[string]SecretOptions{"TWO": {Default: &fallback}},
+			},
+			want: OutputList{
+				{Key: "TWO", Value: "fallback", Comment: "Path: kv2/nope, Key: two, Default", Secret: true},
+			},
+			wantMissing: []MissingSecret{
+				{Key: "ONE", Path: "kv2/nope", VaultKey: "one"},
+				{Key: "TWO", Path: "kv2/nope", VaultKey: "two", UsedDefault: true},
+			},
+		},
+		{
+			name: "Missing path with a required variable",
+			block: KVSecretBlock{
+				Path:       "kv2/nope",
+				Vars:       KVSecret{{"ONE", "one"}, {"TWO", "two"}},
+				VarOptions: map[string]SecretOptions{"TWO": {Optional: &yes}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Optional missing key",
+			block: KVSecretBlock{
+				Path:       "kv2/test",
+				Vars:       KVSecret{{"ONE", "one"}, {"TWO", "two"}},
+				VarOptions: map[string]SecretOptions{"TWO": {Optional: &yes}},
+			},
+			want: OutputList{
+				{Key: "ONE", Value: "1", Comment: "Path: kv2/test, Key: one", Secret: true},
+			},
+			wantMissing: []MissingSecret{
+				{Key: "TWO", Path: "kv2/test", VaultKey: "two"},
+			},
+		},
+		{
+			name: "Required variable in an optional block",
+			block: KVSecretBlock{
+				Path:          "kv2/test",
+				Vars:          KVSecret{{"ONE", "one"}, {"TWO", "two"}},
+				SecretOptions: SecretOptions{Optional: &yes},
+				VarOptions:    map[string]SecretOptions{"TWO": {Optional: &no}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Block default for a missing key",
+			block: KVSecretBlock{
+				Path:          "kv2/test",
+				Vars:          KVSecret{{"ONE", "one"}, {"TWO", "two"}},
+				SecretOptions: SecretOptions{Default: &fallback},
+			},
+			want: OutputList{
+				{Key: "ONE", Value: "1", Comment: "Path: kv2/test, Key: one", Secret: true},
+				{Key: "TWO", Value: "fallback", Comment: "Path: kv2/test, Key: two, Default", Secret: true},
+			},
+			wantMissing: []MissingSecret{
+				{Key: "TWO", Path: "kv2/test", VaultKey: "two", UsedDefault: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := vault.New(vault.WithAddress(server.URL))
+			warnings := &bytes.Buffer{}
+			reader := &Reader{client: client, warnings: warnings}
+			got, err := tt.block.GetOutput(context.Background(), reader)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("KVSecretBlock.GetOutput() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("KVSecretBlock.GetOutput() = %v, want %v", got, tt.want)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(reader.Missing(), tt.wantMissing) {
+				t.Errorf("Reader.Missing() = %v, want %v", reader.Missing(), tt.wantMissing)
+			}
+			if (warnings.Len() > 0) != (len(tt.wantMissing) > 0) {
+				t.Errorf("warnings = %q", warnings.String())
+			}
+		})
+	}
+}
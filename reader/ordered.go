@@ -0,0 +1,159 @@
+package reader
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Named is a variable's name and a value for it. Lists of them keep the
+// order the variables were declared in.
+type Named[T any] struct {
+	Name  string
+	Value T
+}
+
+// Var is a name and its value. EnvVars, Secrets, KVSecret and EncryptedVars
+// are lists of them; ExecVars and Files are lists of other Named values.
+type Var = Named[string]
+
+// lookup finds the value of a name
+func lookup[S ~[]Named[T], T any](vars S, name string) (T, bool) {
+	for _, v := range vars {
+		if v.Name == name {
+			return v.Value, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// varNames lists the names in declaration order
+func varNames[S ~[]Named[T], T any](vars S) []string {
+	names := []string{}
+	for _, v := range vars {
+		names = append(names, v.Name)
+	}
+	return names
+}
+
+// setVar replaces the value of a name where it was declared, or adds it to
+// the end
+func setVar[S ~[]Named[T], T any](vars S, name string, value T) S {
+	for i, v := range vars {
+		if v.Name == name {
+			vars[i].Value = value
+			return vars
+		}
+	}
+	return append(vars, Named[T]{Name: name, Value: value})
+}
+
+// deleteVar removes a name, keeping the order of the rest
+func deleteVar[S ~[]Named[T], T any](vars S, name string) S {
+	for i, v := range vars {
+		if v.Name == name {
+			return append(vars[:i:i], vars[i+1:]...)
+		}
+	}
+	return vars
+}
+
+// mergeVars layers top over base. Names in both keep their place in base.
+func mergeVars[S ~[]Named[T], T any](base S, top S) S {
+	if base == nil && top == nil {
+		return nil
+	}
+	merged := append(S{}, base...)
+	for _, v := range top {
+		merged = setVar(merged, v.Name, v.Value)
+	}
+	return merged
+}
+
+// decodeVars decodes a YAML mapping of names to values in declaration
+// order, leaving out the values skip matches. Values are decoded as they
+// would be on their own unless a decode func is given for them.
+func decodeVars[T any](node *yaml.Node, what string, skip func(*yaml.Node) bool, decode func(*yaml.Node) (T, error)) ([]Named[T], error) {
+	if node.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: %s must be a mapping", node.Line, what)
+	}
+	vars := []Named[T]{}
+	seen := map[string]bool{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if seen[key.Value] {
+			return nil, fmt.Errorf("line %d: %s %q is defined more than once", key.Line, what, key.Value)
+		}
+		seen[key.Value] = true
+		if skip != nil && skip(value) {
+			continue
+		}
+		var val T
+		var err error
+		if decode != nil {
+			val, err = decode(value)
+		} else {
+			err = value.Decode(&val)
+		}
+		if err != nil {
+			return nil, err
+		}
+		vars = append(vars, Named[T]{Name: key.Value, Value: val})
+	}
+	return vars, nil
+}
+
+// marshalVars renders vars as a JSON object, in order
+func marshalVars[T any](vars []Named[T]) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('{')
+	for i, v := range vars {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(v.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(v.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// unmarshalVars reads a JSON object of strings into vars in order, replacing
+// the values of names already there
+func unmarshalVars(data []byte, vars []Var) ([]Var, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, isDelim := token.(json.Delim); !isDelim || delim != '{' {
+		return nil, fmt.Errorf("expected a JSON object")
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		var value string
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+		vars = setVar(vars, token.(string), value)
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
@@ -0,0 +1,87 @@
+package reader
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestEnvVars_DeclarationOrder(t *testing.T) {
+	input := `
+vars:
+  ZEBRA: "z"
+  APPLE: "a"
+  MANGO: "m"
+  BANANA: "b"
+secrets:
+  Z_SECRET: "secret/z"
+  A_SECRET: "secret/a"
+kv_secrets:
+  - path: "secret/app"
+    vars:
+      Z_KEY: "z"
+      A_KEY: "a"
+`
+	var got Variables
+	if err := yaml.Unmarshal([]byte(input), &got); err != nil {
+		t.Fatalf("yaml.Unmarshal() error = %v", err)
+	}
+	if want := (EnvVars{{"ZEBRA", "z"}, {"APPLE", "a"}, {"MANGO", "m"}, {"BANANA", "b"}}); !reflect.DeepEqual(got.Vars, want) {
+		t.Errorf("Vars = %v, want %v", got.Vars, want)
+	}
+	if want := (Secrets{{"Z_SECRET", "secret/z"}, {"A_SECRET", "secret/a"}}); !reflect.DeepEqual(got.Secrets, want) {
+		t.Errorf("Secrets = %v, want %v", got.Secrets, want)
+	}
+	if want := (KVSecret{{"Z_KEY", "z"}, {"A_KEY", "a"}}); !reflect.DeepEqual(got.KVSecrets[0].Vars, want) {
+		t.Errorf("KVSecrets[0].Vars = %v, want %v", got.KVSecrets[0].Vars, want)
+	}
+
+	var dup Variables
+	if err := yaml.Unmarshal([]byte("vars:\n  A: a\n  A: b\n"), &dup); err == nil {
+		t.Errorf("yaml.Unmarshal() of a duplicate variable should fail")
+	}
+}
+
+func TestOutputList_Reproducible(t *testing.T) {
+	input := &Variables{}
+	for _, name := range []string{"M", "B", "Y", "A", "Q", "C", "X", "D"} {
+		input.Vars = append(input.Vars, Var{name, name})
+	}
+	reader, _ := NewReader(WithSkipVault(true))
+
+	first, _ := reader.Read(context.Background(), input, "", "")
+	for i := 0; i < 20; i++ {
+		got, _ := reader.Read(context.Background(), input, "", "")
+		if !reflect.DeepEqual(got, first) {
+			t.Fatalf("Reader.Read() = %v, then %v", first, got)
+		}
+	}
+	if first[1].Key != "M" || first[len(first)-1].Key != "D" {
+		t.Errorf("Reader.Read() = %v, want declaration order", first)
+	}
+}
+
+func TestEnvVars_JSON(t *testing.T) {
+	vars := EnvVars{{"B", "1"}, {"A", "2"}}
+	out, err := json.Marshal(vars)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	if want := `{"B":"1","A":"2"}`; string(out) != want {
+		t.Errorf("json.Marshal() = %s, want %s", out, want)
+	}
+
+	// Later objects replace values in place and add new ones at the end
+	if err := json.Unmarshal([]byte(`{"C": "3", "B": "4"}`), &vars); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if want := (EnvVars{{"B", "4"}, {"A", "2"}, {"C", "3"}}); !reflect.DeepEqual(vars, want) {
+		t.Errorf("json.Unmarshal() = %v, want %v", vars, want)
+	}
+	if err := json.Unmarshal([]byte(`["A"]`), &vars); err == nil {
+		t.Errorf("json.Unmarshal() of a list should fail")
+	}
+}
@@ -0,0 +1,48 @@
+package reader
+
+import "strings"
+
+// Quoter makes a value a single word that a shell reads back byte for byte,
+// without expanding anything in it
+type Quoter func(value string) string
+
+// shellQuoters are the quoting rules of the shells exports can be printed for
+var shellQuoters = map[string]Quoter{
+	"sh":   QuotePOSIX,
+	"bash": QuotePOSIX,
+	"zsh":  QuotePOSIX,
+}
+
+// Shells are the shells exports can be printed for
+func Shells() []string {
+	return sortedKeys(shellQuoters)
+}
+
+// IsShell tells if exports can be printed for a shell
+func IsShell(shell string) bool {
+	_, found := shellQuoters[shell]
+	return found
+}
+
+// QuotePOSIX quotes a value in single quotes, which POSIX shells take
+// literally. Single quotes in the value end the quoting, add an escaped
+// quote, and start it again.
+func QuotePOSIX(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// PrintOptFunc sets an option for printing exports
+type PrintOptFunc func(*printOptions)
+
+type printOptions struct {
+	quote Quoter
+}
+
+// WithShell quotes values for a shell, sh if it isn't one of Shells
+func WithShell(shell string) PrintOptFunc {
+	return func(o *printOptions) {
+		if quote, found := shellQuoters[shell]; found {
+			o.quote = quote
+		}
+	}
+}
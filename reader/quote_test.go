@@ -0,0 +1,75 @@
+package reader
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestQuotePOSIX(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "", want: "''"},
+		{value: "plain", want: "'plain'"},
+		{value: "$(echo bad) `echo bad` $HOME", want: "'$(echo bad) `echo bad` $HOME'"},
+		{value: "it's", want: `'it'\''s'`},
+		{value: `"; echo bad "`, want: `'"; echo bad "'`},
+		{value: "héllo\nworld", want: "'héllo\nworld'"},
+	}
+	for _, tt := range tests {
+		if got := QuotePOSIX(tt.value); got != tt.want {
+			t.Errorf("QuotePOSIX(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestOutputList_Print(t *testing.T) {
+	list := OutputList{
+		{Comment: "Global Variables"},
+		{Key: "PLAIN", Value: "value"},
+		{Key: "QUOTED", Value: "it's $HOME", Comment: "Path: secret/app, Key: quoted"},
+		{Key: "GONE", Unset: true},
+		{Key: "not valid", Value: "skipped"},
+	}
+	var out bytes.Buffer
+	list.print(&out, true, WithShell("bash"))
+	want := `# Global Variables
+export PLAIN='value'
+export QUOTED='it'\''s $HOME' # Path: secret/app, Key: quoted
+unset GONE
+`
+	if out.String() != want {
+		t.Errorf("OutputList.print() = %s, want %s", out.String(), want)
+	}
+}
+
+// FuzzQuotePOSIX checks that sh reads every quoted value back exactly
+func FuzzQuotePOSIX(f *testing.F) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		f.Skip("sh isn't available")
+	}
+	for _, seed := range []string{
+		"", "plain", "it's", "''", `\'`, "$(echo bad)", "`echo bad`", "$HOME ${HOME} $1 $@",
+		"\"; echo bad \"", "a\nb\r\n", "tab\there", "héllo ✓", "\xff\xfe invalid UTF-8", "-n", "%s %%",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, value string) {
+		if strings.ContainsRune(value, 0) {
+			// Neither a shell script nor an environment variable can hold NUL
+			t.Skip()
+		}
+		script := "export VAR=" + QuotePOSIX(value) + "\nprintf %s \"$VAR\""
+		got, err := exec.Command(sh, "-c", script).Output()
+		if err != nil {
+			t.Fatalf("sh -c %q failed: %v", script, err)
+		}
+		if string(got) != value {
+			t.Errorf("sh read %q back as %q", value, got)
+		}
+	})
+}
@@ -6,14 +6,22 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
+	"os/signal"
 	"regexp"
 	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 
+	"filippo.io/age"
 	"github.com/hashicorp/vault-client-go"
+	"gopkg.in/yaml.v3"
 )
 
 type Reader struct {
@@ -21,6 +29,21 @@ type Reader struct {
 	skipVault       bool
 	canDetectMounts bool
 	mounts          Mounts
+	identities      []age.Identity
+	interpolate     bool
+	hostEnv         bool
+	templates       bool
+	kvCache         map[string]map[string]interface{}
+	warnings        io.Writer
+	missing         []MissingSecret
+	fileDir         string
+	cleanedUp       bool
+	filesMu         sync.Mutex
+	allowedRemotes  []string
+	vaultAddress    string
+	vaultNamespace  string
+	inputFormat     string
+	commandLine     OutputList
 }
 
 type ReaderOptFunc func(*Reader)
@@ -31,50 +54,133 @@ func WithSkipVault(skip bool) ReaderOptFunc {
 	}
 }
 
-type EnvVars map[string]string
+// WithVaultAddress sets the Vault server's address, in place of VAULT_ADDR
+func WithVaultAddress(address string) ReaderOptFunc {
+	return func(r *Reader) {
+		r.vaultAddress = address
+	}
+}
+
+// WithVaultNamespace sets the Vault namespace, in place of VAULT_NAMESPACE
+func WithVaultNamespace(namespace string) ReaderOptFunc {
+	return func(r *Reader) {
+		r.vaultNamespace = namespace
+	}
+}
+
+// EnvVars are plain values, in the order they were declared
+type EnvVars []Var
+
+// UnmarshalYAML decodes plain values only; `!encrypted` values are collected
+// separately into the scope's EncryptedVars. Lists are joined with
+// DefaultSeparator and mappings are encoded as JSON.
+func (e *EnvVars) UnmarshalYAML(node *yaml.Node) error {
+	vars, err := decodeVars(node, "vars", func(value *yaml.Node) bool {
+		return value.Tag == EncryptedTag
+	}, func(value *yaml.Node) (string, error) {
+		return nodeValue(value, DefaultSeparator)
+	})
+	*e = vars
+	return err
+}
+
+func (e EnvVars) MarshalJSON() ([]byte, error) {
+	return marshalVars(e)
+}
+
+// UnmarshalJSON adds the values of a JSON object, replacing existing ones
+func (e *EnvVars) UnmarshalJSON(data []byte) error {
+	vars, err := unmarshalVars(data, *e)
+	if err != nil {
+		return err
+	}
+	*e = vars
+	return nil
+}
 
 func (e EnvVars) GetOutput() OutputList {
 	output := OutputList{}
-	for k, v := range e {
+	for _, v := range e {
 		output = append(output, Output{
-			Key:   k,
-			Value: v,
+			Key:   v.Name,
+			Value: v.Value,
 		})
 	}
 	return output
 }
 
-type Secrets map[string]string
+// Secrets map variables to Vault paths, reading the key "value"
+type Secrets []Var
+
+func (s *Secrets) UnmarshalYAML(node *yaml.Node) error {
+	vars, err := decodeVars[string](node, "secrets", nil, nil)
+	*s = vars
+	return err
+}
+
+func (s Secrets) MarshalJSON() ([]byte, error) {
+	return marshalVars(s)
+}
 
 var shellvar_regexp = regexp.MustCompile("^[_A-Za-z][A-Za-z0-9_]*$")
 
 func (s Secrets) GetOutput(ctx context.Context, r *Reader) (OutputList, error) {
+	return s.read(ctx, r, nil)
+}
+
+// read reads the secrets, joining list values with the given separators
+func (s Secrets) read(ctx context.Context, r *Reader, separators Separators) (OutputList, error) {
 	// Read it like a kv secrets where all keys are "value"
 	kvSecrets := KVSecrets{}
-	for outVar, path := range s {
+	for _, secret := range s {
 		kvSecret := KVSecretBlock{
-			Path: path,
+			Path: secret.Value,
 			Vars: KVSecret{
-				outVar: "value",
+				{Name: secret.Name, Value: "value"},
 			},
 		}
 		kvSecrets = append(kvSecrets, kvSecret)
 	}
-	return kvSecrets.GetOutput(ctx, r)
+	return kvSecrets.read(ctx, r, separators)
+}
+
+// KVSecret maps variables to keys at a path, in the order they were declared
+type KVSecret []Var
+
+func (s *KVSecret) UnmarshalYAML(node *yaml.Node) error {
+	vars, err := decodeVars[string](node, "vars", nil, nil)
+	*s = vars
+	return err
 }
 
-type KVSecret map[string]string
+func (s KVSecret) MarshalJSON() ([]byte, error) {
+	return marshalVars(s)
+}
 
 type KVSecretBlock struct {
 	Path string
 	Vars KVSecret
+	// SecretOptions apply to every variable in the block
+	SecretOptions
+	// VarOptions are options given to single variables, by name
+	VarOptions map[string]SecretOptions `json:",omitempty"`
 }
 
 type KVSecrets []KVSecretBlock
 
 func (s KVSecretBlock) GetOutput(ctx context.Context, r *Reader) (OutputList, error) {
-	output := OutputList{}
+	return r.readBlock(ctx, s.Path, s.Vars, false, s.SecretOptions, s.VarOptions, nil)
+}
+
+// readKV reads the data at a KV path, autodetecting the engine version unless
+// kv1 is set. Each path is only read once per Reader.
+func (r *Reader) readKV(ctx context.Context, path string, kv1 bool) (map[string]interface{}, error) {
+	return r.readKVVersion(ctx, path, kv1, 0)
+}
 
+// readKVVersion reads a version of a KV v2 secret, or the latest when version
+// is 0
+func (r *Reader) readKVVersion(ctx context.Context, path string, kv1 bool, version int) (map[string]interface{}, error) {
 	// Initialize the Vault Client if Necessary
 	if r.client == nil {
 		err := r.InitVault()
@@ -84,65 +190,68 @@ func (s KVSecretBlock) GetOutput(ctx context.Context, r *Reader) (OutputList, er
 	}
 
 	// Get the Mount Point for the Secret
-	mountPoint, secretPath := r.MountAndPath(s.Path)
+	mountPoint, secretPath := r.MountAndPath(path)
 	if mountPoint == "" {
-		return nil, fmt.Errorf("no mount point found for path %s", s.Path)
+		return nil, fmt.Errorf("no mount point found for path %s", path)
 	}
 
 	// Assume v2 if we can detect mounts and it's a KV engine, or if it's explicitly v2
-	if !r.canDetectMounts || (r.mounts[mountPoint].Type == "kv" && r.mounts[mountPoint].Version == "2") {
+	kv2 := !kv1 && (!r.canDetectMounts || (r.mounts[mountPoint].Type == "kv" && r.mounts[mountPoint].Version == "2"))
+	cacheKey := fmt.Sprintf("kv1:%s", path)
+	if kv2 {
+		cacheKey = fmt.Sprintf("kv2:%s", path)
+	} else if version != 0 {
+		return nil, fmt.Errorf("unable to read version %d of %s: versions need a kv2 engine", version, path)
+	}
+	if version != 0 {
+		cacheKey = fmt.Sprintf("%s@%d", cacheKey, version)
+	}
+	if data, cached := r.kvCache[cacheKey]; cached {
+		return data, nil
+	}
+
+	var data map[string]interface{}
+	if kv2 {
 		// Get Secret
-		resp, err := r.client.Secrets.KvV2Read(ctx, secretPath, vault.WithMountPath(mountPoint))
+		options := []vault.RequestOption{vault.WithMountPath(mountPoint)}
+		if version != 0 {
+			options = append(options, vault.WithQueryParameters(url.Values{"version": {strconv.Itoa(version)}}))
+		}
+		resp, err := r.client.Secrets.KvV2Read(ctx, secretPath, options...)
 		if err != nil {
 			if vault.IsErrorStatus(err, http.StatusNotFound) {
-				return nil, fmt.Errorf("kv2 secret does not exist: '%s'", s.Path)
+				return nil, fmt.Errorf("kv2 %w: '%s'", ErrSecretNotFound, path)
 			}
-			return nil, fmt.Errorf("error reading kv2 path '%s': %w", s.Path, err)
-		}
-		// For testing purposes, we want to order this
-		envVars := []string{}
-		for varName := range s.Vars {
-			envVars = append(envVars, varName)
-		}
-		slices.Sort(envVars)
-		for _, varName := range envVars {
-			varKey := s.Vars[varName]
-			if _, hasValue := resp.Data.Data[varKey]; !hasValue {
-				return nil, fmt.Errorf("key %s not found in path %s", varKey, s.Path)
-			}
-			val := fmt.Sprintf("%s", resp.Data.Data[varKey])
-			output = append(output, Output{
-				Key:     varName,
-				Value:   val,
-				Comment: fmt.Sprintf("Path: %s, Key: %s", s.Path, varKey),
-			})
+			return nil, fmt.Errorf("error reading kv2 path '%s': %w", path, err)
 		}
+		data = resp.Data.Data
 	} else {
 		// Treat it as a KVv1 secret
 		resp, err := r.client.Secrets.KvV1Read(ctx, secretPath, vault.WithMountPath(mountPoint))
 		if err != nil {
-			return nil, fmt.Errorf("error reading kv1 path %s: %w", s.Path, err)
-		}
-		for varName, varKey := range s.Vars {
-			if _, hasValue := resp.Data[varKey]; !hasValue {
-				return nil, fmt.Errorf("key %s not found in path %s", varKey, s.Path)
+			if vault.IsErrorStatus(err, http.StatusNotFound) {
+				return nil, fmt.Errorf("kv1 %w: '%s'", ErrSecretNotFound, path)
 			}
-			val := fmt.Sprintf("%s", resp.Data[varKey])
-			output = append(output, Output{
-				Key:     varName,
-				Value:   val,
-				Comment: fmt.Sprintf("Path: %s, Key: %s", s.Path, varKey),
-			})
+			return nil, fmt.Errorf("error reading kv1 path %s: %w", path, err)
 		}
+		data = resp.Data
 	}
 
-	return output, nil
+	if r.kvCache == nil {
+		r.kvCache = map[string]map[string]interface{}{}
+	}
+	r.kvCache[cacheKey] = data
+	return data, nil
 }
 
 func (s KVSecrets) GetOutput(ctx context.Context, r *Reader) (OutputList, error) {
+	return s.read(ctx, r, nil)
+}
+
+func (s KVSecrets) read(ctx context.Context, r *Reader, separators Separators) (OutputList, error) {
 	output := OutputList{}
 	for _, block := range s {
-		blockOutput, err := block.GetOutput(ctx, r)
+		blockOutput, err := r.readBlock(ctx, block.Path, block.Vars, false, block.SecretOptions, block.VarOptions, separators)
 		if err != nil {
 			return nil, err
 		}
@@ -157,112 +266,224 @@ type KV1Secrets []KV1SecretBlock
 type KV1SecretBlock struct {
 	Path string
 	Vars KVSecret
+	SecretOptions
+	VarOptions map[string]SecretOptions `json:",omitempty"`
 }
 
 func (s KV1SecretBlock) GetOutput(ctx context.Context, r *Reader) (OutputList, error) {
-	output := OutputList{}
+	return r.readBlock(ctx, s.Path, s.Vars, true, s.SecretOptions, s.VarOptions, nil)
+}
 
-	// Initialize the Vault Client if Necessary
-	if r.client == nil {
-		err := r.InitVault()
+func (s KV1Secrets) GetOutput(ctx context.Context, r *Reader) (OutputList, error) {
+	return s.read(ctx, r, nil)
+}
+
+func (s KV1Secrets) read(ctx context.Context, r *Reader, separators Separators) (OutputList, error) {
+	output := OutputList{}
+	for _, block := range s {
+		blockOutput, err := r.readBlock(ctx, block.Path, block.Vars, true, block.SecretOptions, block.VarOptions, separators)
 		if err != nil {
 			return nil, err
 		}
+		output = append(output, blockOutput...)
 	}
+	return output, nil
+}
 
-	// The first thing we need to do is get the mount point for the KV engine
-	mountPoint, secretPath := r.MountAndPath(s.Path)
-	if mountPoint == "" {
-		return nil, fmt.Errorf("no mount point found for path %s", s.Path)
-	}
+// scope holds the sources shared by the global, environment and datacenter
+// levels and the scopes of other dimensions
+type scope struct {
+	Vars       EnvVars       `yaml:"vars,omitempty"`
+	Separators Separators    `yaml:"separators,omitempty"`
+	EnvFiles   EnvFiles      `yaml:"env_files,omitempty"`
+	FileVars   FileVars      `yaml:"file_vars,omitempty"`
+	ExecVars   ExecVars      `yaml:"exec_vars,omitempty"`
+	Files      Files         `yaml:"files,omitempty"`
+	Required   []string      `yaml:"required,omitempty"`
+	FromEnv    FromEnv       `yaml:"from_env,omitempty"`
+	Secrets    Secrets       `yaml:"secrets,omitempty"`
+	KVSecrets  KVSecrets     `yaml:"kv_secrets,omitempty"`
+	KV1Secrets KV1Secrets    `yaml:"kv1_secrets,omitempty"`
+	Encrypted  EncryptedVars `yaml:"-"`
+	// Unset removes values set by broader scopes
+	Unset []string `yaml:"unset,omitempty"`
+	// varOrder is the names in vars, plain and encrypted, in declared order
+	varOrder []string
+	// lists holds the items of list values in vars, which are joined with
+	// separators once the files and scopes they're declared in are merged
+	lists []Named[[]string]
+}
 
-	// Treat it as a KVv1 secret
-	resp, err := r.client.Secrets.KvV1Read(ctx, secretPath, vault.WithMountPath(mountPoint))
+// finishDecode finishes decoding a scope's vars from its node: list values are
+// joined with their separators, and !encrypted values are collected
+func (s *scope) finishDecode(node *yaml.Node) error {
+	lists, err := decodeLists(node)
 	if err != nil {
-		return nil, fmt.Errorf("error reading kv1 path %s: %w", s.Path, err)
+		return err
 	}
-	for varName, varKey := range s.Vars {
-		if _, hasValue := resp.Data[varKey]; !hasValue {
-			return nil, fmt.Errorf("key %s not found in path %s", varKey, s.Path)
+	s.lists = lists
+	s.Vars = s.joinLists(s.Vars)
+	if varsNode := mappingValue(node, "vars"); varsNode != nil && varsNode.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(varsNode.Content); i += 2 {
+			s.varOrder = append(s.varOrder, varsNode.Content[i].Value)
 		}
-		val := fmt.Sprintf("%s", resp.Data[varKey])
-		output = append(output, Output{
-			Key:     varName,
-			Value:   val,
-			Comment: fmt.Sprintf("Path: %s, Key: %s", s.Path, varKey),
-		})
 	}
-
-	return output, nil
+	return decodeEncrypted(node, &s.Encrypted)
 }
 
-func (s KV1Secrets) GetOutput(ctx context.Context, r *Reader) (OutputList, error) {
-	output := OutputList{}
-	for _, block := range s {
-		blockOutput, err := block.GetOutput(ctx, r)
-		if err != nil {
-			return nil, err
+// inVarOrder puts the output of plain and encrypted vars back in the order
+// they were declared. Names that weren't declared in a file come last.
+func (s scope) inVarOrder(output OutputList) OutputList {
+	index := func(name string) int {
+		if i := slices.Index(s.varOrder, name); i >= 0 {
+			return i
 		}
-		output = append(output, blockOutput...)
+		return len(s.varOrder)
 	}
-	return output, nil
+	slices.SortStableFunc(output, func(a, b Output) int {
+		return index(a.Key) - index(b.Key)
+	})
+	return output
+}
+
+// sourcedScope is a scope being read, with what's needed to say where each of
+// its values came from
+type sourcedScope struct {
+	scope
+	file      string
+	path      string
+	origins   map[string]string
+	inherited map[string]string
 }
 
 type DC struct {
-	Vars       EnvVars    `yaml:"vars,omitempty"`
-	Secrets    Secrets    `yaml:"secrets,omitempty"`
-	KVSecrets  KVSecrets  `yaml:"kv_secrets,omitempty"`
-	KV1Secrets KV1Secrets `yaml:"kv1_secrets,omitempty"`
+	scope   `yaml:",inline"`
+	Extends Extends `yaml:"extends,omitempty"`
+	Scopes  Scopes  `yaml:"scopes,omitempty"`
 }
 
 type Environment struct {
-	Vars       EnvVars       `yaml:"vars,omitempty"`
-	Secrets    Secrets       `yaml:"secrets,omitempty"`
-	KVSecrets  KVSecrets     `yaml:"kv_secrets,omitempty"`
-	KV1Secrets KV1Secrets    `yaml:"kv1_secrets,omitempty"`
-	Dcs        map[string]DC `yaml:"dcs,omitempty"`
+	scope   `yaml:",inline"`
+	Extends Extends       `yaml:"extends,omitempty"`
+	Scopes  Scopes        `yaml:"scopes,omitempty"`
+	Dcs     map[string]DC `yaml:"dcs,omitempty"`
 }
 
 type Variables struct {
-	Vars         EnvVars                `yaml:"vars,omitempty"`
-	Secrets      Secrets                `yaml:"secrets,omitempty"`
-	KVSecrets    KVSecrets              `yaml:"kv_secrets,omitempty"`
-	KV1Secrets   KV1Secrets             `yaml:"kv1_secrets,omitempty"`
+	Include      []string `yaml:"include,omitempty"`
+	Dimensions   []string `yaml:"dimensions,omitempty"`
+	scope        `yaml:",inline"`
+	Scopes       Scopes                 `yaml:"scopes,omitempty"`
 	Environments map[string]Environment `yaml:"environments,omitempty"`
+	// DefaultEnvironment and DefaultDC are selected when -e or -d aren't given
+	DefaultEnvironment string `yaml:"default_environment,omitempty"`
+	DefaultDC          string `yaml:"default_dc,omitempty"`
+	// File is where the variables were loaded from, for error messages
+	File string `yaml:"-"`
+	// Origins maps each value, e.g. "environments.stage.vars.FOO", to the file it came from
+	Origins map[string]string `yaml:"-"`
+	// Inherited maps values that came from an extended environment or datacenter to that ancestor
+	Inherited map[string]string `yaml:"-"`
 }
 
 type Output struct {
 	Key     string
 	Value   string
 	Comment string
+	// Secret marks values that came from Vault or were encrypted
+	Secret bool
+	// Unset removes the variable instead of setting it
+	Unset bool `json:",omitempty"`
+	// Origin is the file that set the value, if known
+	Origin string `json:",omitempty"`
+	// Scope and Overrides are set by Resolve: the scope the value came from,
+	// and the scopes whose values it replaced
+	Scope     string   `json:",omitempty"`
+	Overrides []string `json:",omitempty"`
 }
 type OutputList []Output
 
-func (o OutputList) Exec(shell_cmd string) int {
-	shell, shell_isset := os.LookupEnv("SHELL")
+// RedactedValue replaces secret values in debug output
+const RedactedValue = "[redacted]"
 
-	var cmd *exec.Cmd
+// Redacted returns a copy of the list with secret values replaced, suitable for logging
+func (o OutputList) Redacted() OutputList {
+	redacted := make(OutputList, len(o))
+	for i, out := range o {
+		if out.Secret {
+			out.Value = RedactedValue
+		}
+		redacted[i] = out
+	}
+	return redacted
+}
 
-	if shell_isset {
-		cmd = exec.Command(shell, "-c", shell_cmd)
-	} else {
-		cmd = exec.Command("/usr/bin/env", "bash", "-c", shell_cmd)
+// ExecOptFunc sets an option for Exec
+type ExecOptFunc func(*execOptions)
+
+type execOptions struct {
+	unsetInherited bool
+}
+
+// WithUnsetInherited makes unset variables also remove the values the
+// command would inherit from this process
+func WithUnsetInherited(unsetInherited bool) ExecOptFunc {
+	return func(o *execOptions) {
+		o.unsetInherited = unsetInherited
 	}
+}
+
+func (o OutputList) Exec(shell_cmd string, opts ...ExecOptFunc) int {
+	options := execOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	cmd := shellCommand(context.Background(), shell_cmd)
 
+	inherited := cmd.Environ()
+	env := []string{}
 	for _, out := range o {
-		if shellvar_regexp.MatchString(out.Key) {
-			s := fmt.Sprintf("%s=%s", out.Key, out.Value)
-			cmd.Env = append(cmd.Environ(), s)
+		if !shellvar_regexp.MatchString(out.Key) {
+			continue
 		}
+		if out.Unset {
+			env = withoutVar(env, out.Key)
+			if options.unsetInherited {
+				inherited = withoutVar(inherited, out.Key)
+			}
+			continue
+		}
+		env = append(env, fmt.Sprintf("%s=%s", out.Key, out.Value))
 	}
+	cmd.Env = append(inherited, env...)
 
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
-	if err := cmd.Run(); err != nil {
+	// Pass SIGINT and SIGTERM on to the command rather than exiting before it
+	// does, so the caller can clean up after it
+	if err := cmd.Start(); err != nil {
+		return -1
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		for sig := range signals {
+			cmd.Process.Signal(sig)
+		}
+	}()
+	err := cmd.Wait()
+	signal.Stop(signals)
+	close(signals)
+	if err != nil {
 		var exitError *exec.ExitError
 		if errors.As(err, &exitError) {
+			// Like a shell, report a command killed by a signal as 128+n
+			if status, ok := exitError.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+				return 128 + int(status.Signal())
+			}
 			return exitError.ExitCode()
 		}
 		return -1
@@ -270,12 +491,25 @@ func (o OutputList) Exec(shell_cmd string) int {
 	return 0
 }
 
+// withoutVar removes a variable from a list of KEY=value pairs
+func withoutVar(env []string, key string) []string {
+	kept := []string{}
+	for _, pair := range env {
+		if !strings.HasPrefix(pair, key+"=") {
+			kept = append(kept, pair)
+		}
+	}
+	return kept
+}
+
 func (ol OutputList) PrintB64Json() error {
 	envs := EnvVars{}
 
 	for _, o := range ol {
-		if o.Key != "" {
-			envs[o.Key] = o.Value
+		if o.Unset {
+			envs = deleteVar(envs, o.Key)
+		} else if o.Key != "" {
+			envs = setVar(envs, o.Key, o.Value)
 		}
 	}
 
@@ -290,20 +524,33 @@ func (ol OutputList) PrintB64Json() error {
 	return nil
 }
 
-func (o OutputList) Print(showComments bool) {
+// Print writes the exports, quoting values for a shell with WithShell
+func (o OutputList) Print(showComments bool, opts ...PrintOptFunc) {
+	o.print(os.Stdout, showComments, opts...)
+}
+
+func (o OutputList) print(w io.Writer, showComments bool, opts ...PrintOptFunc) {
+	options := printOptions{quote: QuotePOSIX}
+	for _, opt := range opts {
+		opt(&options)
+	}
 	for _, out := range o {
 		if out.Key == "" {
 			if showComments && out.Comment != "" {
-				fmt.Printf("# %s\n", out.Comment)
+				fmt.Fprintf(w, "# %s\n", out.Comment)
 			}
 		} else {
 			/* silently discards variable names that are not shell safe */
 			if shellvar_regexp.MatchString(out.Key) {
-				fmt.Printf("export %s=%q", out.Key, out.Value)
+				if out.Unset {
+					fmt.Fprintf(w, "unset %s", out.Key)
+				} else {
+					fmt.Fprintf(w, "export %s=%s", out.Key, options.quote(out.Value))
+				}
 				if out.Comment != "" && showComments {
-					fmt.Printf(" # %s", out.Comment)
+					fmt.Fprintf(w, " # %s", out.Comment)
 				}
-				fmt.Println()
+				fmt.Fprintln(w)
 			}
 		}
 	}
@@ -321,10 +568,19 @@ func (r *Reader) InitVault() error {
 		return nil
 	}
 
-	vaultClient, err := vault.New(vault.WithEnvironment())
+	options := []vault.ClientOption{vault.WithEnvironment()}
+	if r.vaultAddress != "" {
+		options = append(options, vault.WithAddress(r.vaultAddress))
+	}
+	vaultClient, err := vault.New(options...)
 	if err != nil {
 		return err
 	}
+	if r.vaultNamespace != "" {
+		if err := vaultClient.SetNamespace(r.vaultNamespace); err != nil {
+			return err
+		}
+	}
 	r.client = vaultClient
 	r.canDetectMounts = false
 
@@ -376,90 +632,95 @@ func (r *Reader) MountAndPath(path string) (string, string) {
 	return "", ""
 }
 
-func (r *Reader) Read(ctx context.Context, input *Variables, env string, dc string) (OutputList, error) {
+func (r *Reader) readScope(ctx context.Context, s sourcedScope, data map[string]string) (OutputList, error) {
 	output := OutputList{}
 
-	// Global Variables
-	output = append(output, Output{
-		Comment: "Global Variables",
-	})
-	output = append(output, input.Vars.GetOutput()...)
+	// Unset comes first, so it only removes values from broader scopes
+	for _, name := range s.Unset {
+		output = append(output, s.annotateOutput(Output{Key: name, Unset: true}, "unset."+name))
+	}
 
-	if !r.skipVault {
-		// Global Secrets
-		kvOut, err := input.KVSecrets.GetOutput(ctx, r)
+	varsOut := s.joinLists(s.Vars).GetOutput()
+	if r.templates {
+		var err error
+		varsOut, err = renderTemplates(varsOut, s, data)
 		if err != nil {
-			return nil, fmt.Errorf("kv secret error: %w", err)
+			return nil, fmt.Errorf("template error: %w", err)
 		}
-		output = append(output, kvOut...)
-		kv1Out, err := input.KV1Secrets.GetOutput(ctx, r)
+	}
+	varsOut, err := r.resolveSecretRefs(ctx, varsOut, s.Separators)
+	if err != nil {
+		return nil, fmt.Errorf("secret reference error: %w", err)
+	}
+	if !r.skipVault {
+		// Encrypted vars are secrets too, but keep their place among the vars
+		encOut, err := s.Encrypted.GetOutput(r)
 		if err != nil {
-			return nil, fmt.Errorf("kv1 secret error: %w", err)
+			return nil, fmt.Errorf("encrypted value error: %w", err)
 		}
-		output = append(output, kv1Out...)
-		secretOut, err := input.Secrets.GetOutput(ctx, r)
+		varsOut = s.inVarOrder(append(varsOut, encOut...))
+	}
+	output = append(output, s.annotate(varsOut, "vars")...)
+
+	for _, envFile := range s.EnvFiles {
+		fileOut, err := ReadEnvFile(envFile)
 		if err != nil {
-			return nil, fmt.Errorf("secret error: %w", err)
+			return nil, fmt.Errorf("env file error: %w", err)
+		}
+		for i := range fileOut {
+			fileOut[i] = s.annotateOutput(fileOut[i], "env_files."+envFile)
 		}
-		output = append(output, secretOut...)
+		output = append(output, fileOut...)
 	}
 
-	// Environment Variablers
-	if env != "" {
-		output = append(output, Output{
-			Comment: fmt.Sprintf("Environment: %s", env),
-		})
-		output = append(output, input.Environments[env].Vars.GetOutput()...)
-		// KV (autodetect or v2)
-		if !r.skipVault {
-			kvOut, err := input.Environments[env].KVSecrets.GetOutput(ctx, r)
-			if err != nil {
-				return nil, fmt.Errorf("kv secret error: %w", err)
-			}
-			output = append(output, kvOut...)
-			// KV1
-			kv1Out, err := input.Environments[env].KV1Secrets.GetOutput(ctx, r)
-			if err != nil {
-				return nil, fmt.Errorf("kv1 secret error: %w", err)
-			}
-			output = append(output, kv1Out...)
-			// Secrets
-			secretOut, err := input.Environments[env].Secrets.GetOutput(ctx, r)
-			if err != nil {
-				return nil, fmt.Errorf("secret error: %w", err)
-			}
-			output = append(output, secretOut...)
-		}
+	fileVarsOut, err := s.FileVars.GetOutput()
+	if err != nil {
+		return nil, fmt.Errorf("file vars error: %w", err)
 	}
+	output = append(output, s.annotate(fileVarsOut, "file_vars")...)
 
-	// DC Variables
-	if dc != "" {
-		output = append(output, Output{
-			Comment: fmt.Sprintf("Datacenter: %s", dc),
-		})
-		output = append(output, input.Environments[env].Dcs[dc].Vars.GetOutput()...)
+	execOut, err := s.ExecVars.GetOutput(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("exec error: %w", err)
+	}
+	output = append(output, s.annotate(execOut, "exec_vars")...)
 
-		if !r.skipVault {
-			// KV (autodetect or v2)
-			kvOut, err := input.Environments[env].Dcs[dc].KVSecrets.GetOutput(ctx, r)
-			if err != nil {
-				return nil, fmt.Errorf("kv secret error: %w", err)
-			}
-			output = append(output, kvOut...)
-			// KV1
-			kv1Out, err := input.Environments[env].Dcs[dc].KV1Secrets.GetOutput(ctx, r)
-			if err != nil {
-				return nil, fmt.Errorf("kv1 secret error: %w", err)
-			}
-			output = append(output, kv1Out...)
-			// Secrets
-			secretOut, err := input.Environments[env].Dcs[dc].Secrets.GetOutput(ctx, r)
-			if err != nil {
-				return nil, fmt.Errorf("secret error: %w", err)
-			}
-			output = append(output, secretOut...)
+	filesOut, err := s.Files.GetOutput(ctx, r)
+	if err != nil {
+		return nil, fmt.Errorf("files error: %w", err)
+	}
+	output = append(output, s.annotate(filesOut, "files")...)
+
+	output = append(output, s.hostOutput()...)
+
+	if !r.skipVault {
+		// KV (autodetect or v2)
+		kvOut, err := s.KVSecrets.read(ctx, r, s.Separators)
+		if err != nil {
+			return nil, fmt.Errorf("kv secret error: %w", err)
 		}
+		output = append(output, s.annotate(kvOut, "kv_secrets")...)
+		// KV1
+		kv1Out, err := s.KV1Secrets.read(ctx, r, s.Separators)
+		if err != nil {
+			return nil, fmt.Errorf("kv1 secret error: %w", err)
+		}
+		output = append(output, s.annotate(kv1Out, "kv1_secrets")...)
+		// Secrets
+		secretOut, err := s.Secrets.read(ctx, r, s.Separators)
+		if err != nil {
+			return nil, fmt.Errorf("secret error: %w", err)
+		}
+		output = append(output, s.annotate(secretOut, "secrets")...)
 	}
 
 	return output, nil
 }
+
+// Read reads the global values, then those of the environment and datacenter
+func (r *Reader) Read(ctx context.Context, input *Variables, env string, dc string) (OutputList, error) {
+	return r.ReadScopes(ctx, input, Selection{
+		EnvironmentDimension: env,
+		DCDimension:          dc,
+	})
+}
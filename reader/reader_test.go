@@ -25,7 +25,7 @@ func TestEnvVars_GetOutput(t *testing.T) {
 		{
 			name: "Test Output",
 			e: EnvVars{
-				"a": "b",
+				{"a", "b"},
 			},
 			want: OutputList{
 				{
@@ -72,25 +72,33 @@ func TestReader_Read(t *testing.T) {
 				env: "dev",
 				dc:  "us-least-1",
 				input: &Variables{
-					Vars: EnvVars{
-						"FOO": "bar",
+					scope: scope{
+						Vars: EnvVars{
+							{"FOO", "bar"},
+						},
 					},
 					Environments: map[string]Environment{
 						"dev": {
-							Vars: EnvVars{
-								"ENV": "dev",
+							scope: scope{
+								Vars: EnvVars{
+									{"ENV", "dev"},
+								},
 							},
 							Dcs: map[string]DC{
 								"us-least-1": {
-									Vars: EnvVars{
-										"DC": "us-least-1",
+									scope: scope{
+										Vars: EnvVars{
+											{"DC", "us-least-1"},
+										},
 									},
 								},
 							},
 						},
 						"stage": {
-							Vars: EnvVars{
-								"env": "stage",
+							scope: scope{
+								Vars: EnvVars{
+									{"env", "stage"},
+								},
 							},
 						},
 					},
@@ -152,6 +160,8 @@ func TestKVSecretBlock_GetOutputNoDetect(t *testing.T) {
 			resp = []byte(`{"request_id":"bf3b02c0-096e-84d3-dad7-196aa9f112ed","lease_id":"","renewable":false,"lease_duration":0,"data":{"data":{"one":"1","two":"2","three":"3"},"metadata":{"created_time":"2023-12-20T15:32:32.814115685Z","custom_metadata":null,"deletion_time":"","destroyed":false,"version":1}},"wrap_info":null,"warnings":null,"auth":null}`)
 		case "/v1/kv/test":
 			resp = []byte(`{"request_id":"63c8c31b-f03f-81ac-cfaa-324239789c3f","lease_id":"","renewable":false,"lease_duration":2764800,"data":{"value":"old"},"wrap_info":null,"warnings":null,"auth":null}`)
+		case "/v1/kv2/data/numbers":
+			resp = []byte(`{"data":{"data":{"id":12345678901234567,"ids":[12345678901234567,1.5]},"metadata":{"version":1}}}`)
 		default:
 			status = http.StatusNotFound
 			resp = []byte(`{"errors":[]}`)
@@ -190,7 +200,7 @@ func TestKVSecretBlock_GetOutputNoDetect(t *testing.T) {
 			fields: fields{
 				Path: "kv2/path",
 				Vars: KVSecret{
-					"NOT": "here",
+					{"NOT", "here"},
 				},
 			},
 			wantErr: true,
@@ -204,7 +214,7 @@ func TestKVSecretBlock_GetOutputNoDetect(t *testing.T) {
 			fields: fields{
 				Path: "kv2/test",
 				Vars: KVSecret{
-					"THREE": "nope",
+					{"THREE", "nope"},
 				},
 			},
 			wantErr: true,
@@ -218,7 +228,7 @@ func TestKVSecretBlock_GetOutputNoDetect(t *testing.T) {
 			fields: fields{
 				Path: "kv/test",
 				Vars: KVSecret{
-					"VALUE": "value",
+					{"VALUE", "value"},
 				},
 			},
 			wantErr: true,
@@ -231,9 +241,9 @@ func TestKVSecretBlock_GetOutputNoDetect(t *testing.T) {
 			fields: fields{
 				Path: "kv2/test",
 				Vars: KVSecret{
-					"ONE":   "one",
-					"TWO":   "two",
-					"THREE": "three",
+					{"ONE", "one"},
+					{"TWO", "two"},
+					{"THREE", "three"},
 				},
 			},
 			want: OutputList{
@@ -241,19 +251,49 @@ func TestKVSecretBlock_GetOutputNoDetect(t *testing.T) {
 					Key:     "ONE",
 					Value:   "1",
 					Comment: "Path: kv2/test, Key: one",
+					Secret:  true,
+				},
+				{
+					Key:     "TWO",
+					Value:   "2",
+					Comment: "Path: kv2/test, Key: two",
+					Secret:  true,
 				},
 				{
 					Key:     "THREE",
 					Value:   "3",
 					Comment: "Path: kv2/test, Key: three",
+					Secret:  true,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Test KV2 Numbers",
+			args: args{
+				r: reader,
+			},
+			fields: fields{
+				Path: "kv2/numbers",
+				Vars: KVSecret{
+					{"ID", "id"},
+					{"IDS", "ids"},
 				},
+			},
+			want: OutputList{
 				{
-					Key:     "TWO",
-					Value:   "2",
-					Comment: "Path: kv2/test, Key: two",
+					Key:     "ID",
+					Value:   "12345678901234567",
+					Comment: "Path: kv2/numbers, Key: id",
+					Secret:  true,
+				},
+				{
+					Key:     "IDS",
+					Value:   "12345678901234567,1.5",
+					Comment: "Path: kv2/numbers, Key: ids",
+					Secret:  true,
 				},
 			},
-			wantErr: false,
 		},
 	}
 	for _, tt := range tests {
@@ -337,7 +377,7 @@ func TestKVSecretBlock_GetOutput(t *testing.T) {
 			fields: fields{
 				Path: "secret/test",
 				Vars: KVSecret{
-					"should": "fail",
+					{"should", "fail"},
 				},
 			},
 			want:    nil,
@@ -351,7 +391,7 @@ func TestKVSecretBlock_GetOutput(t *testing.T) {
 			fields: fields{
 				Path: "kv2/path",
 				Vars: KVSecret{
-					"NOT": "here",
+					{"NOT", "here"},
 				},
 			},
 			wantErr: true,
@@ -365,7 +405,7 @@ func TestKVSecretBlock_GetOutput(t *testing.T) {
 			fields: fields{
 				Path: "kv2/test",
 				Vars: KVSecret{
-					"THREE": "nope",
+					{"THREE", "nope"},
 				},
 			},
 			wantErr: true,
@@ -379,7 +419,7 @@ func TestKVSecretBlock_GetOutput(t *testing.T) {
 			fields: fields{
 				Path: "kv/test",
 				Vars: KVSecret{
-					"VALUE": "value",
+					{"VALUE", "value"},
 				},
 			},
 			want: OutputList{
@@ -387,6 +427,7 @@ func TestKVSecretBlock_GetOutput(t *testing.T) {
 					Key:     "VALUE",
 					Value:   "old",
 					Comment: "Path: kv/test, Key: value",
+					Secret:  true,
 				},
 			},
 		},
@@ -398,9 +439,9 @@ func TestKVSecretBlock_GetOutput(t *testing.T) {
 			fields: fields{
 				Path: "kv2/test",
 				Vars: KVSecret{
-					"ONE":   "one",
-					"TWO":   "two",
-					"THREE": "three",
+					{"ONE", "one"},
+					{"TWO", "two"},
+					{"THREE", "three"},
 				},
 			},
 			want: OutputList{
@@ -408,16 +449,19 @@ func TestKVSecretBlock_GetOutput(t *testing.T) {
 					Key:     "ONE",
 					Value:   "1",
 					Comment: "Path: kv2/test, Key: one",
-				},
-				{
-					Key:     "THREE",
-					Value:   "3",
-					Comment: "Path: kv2/test, Key: three",
+					Secret:  true,
 				},
 				{
 					Key:     "TWO",
 					Value:   "2",
 					Comment: "Path: kv2/test, Key: two",
+					Secret:  true,
+				},
+				{
+					Key:     "THREE",
+					Value:   "3",
+					Comment: "Path: kv2/test, Key: three",
+					Secret:  true,
 				},
 			},
 			wantErr: false,
@@ -502,7 +546,7 @@ func TestKV1SecretBlock_GetOutput(t *testing.T) {
 			fields: fields{
 				Path: "secret/test",
 				Vars: KVSecret{
-					"should": "fail",
+					{"should", "fail"},
 				},
 			},
 			want:    nil,
@@ -516,7 +560,7 @@ func TestKV1SecretBlock_GetOutput(t *testing.T) {
 			fields: fields{
 				Path: "kv/path",
 				Vars: KVSecret{
-					"NOT": "here",
+					{"NOT", "here"},
 				},
 			},
 			wantErr: true,
@@ -530,7 +574,7 @@ func TestKV1SecretBlock_GetOutput(t *testing.T) {
 			fields: fields{
 				Path: "kv/test",
 				Vars: KVSecret{
-					"VALUE": "value",
+					{"VALUE", "value"},
 				},
 			},
 			want: OutputList{
@@ -538,6 +582,7 @@ func TestKV1SecretBlock_GetOutput(t *testing.T) {
 					Key:     "VALUE",
 					Value:   "old",
 					Comment: "Path: kv/test, Key: value",
+					Secret:  true,
 				},
 			},
 		},
@@ -586,22 +631,27 @@ func TestSkipVault_Reader(t *testing.T) {
 				dc:   "us-least-1",
 				r:    reader,
 				i: &Variables{
-					Vars: EnvVars{
-						"XYZ": "yep",
+					scope: scope{
+						Vars: EnvVars{
+							{"XYZ", "yep"},
+						},
+						Secrets: Secrets{
+							{"Secret1", "it's here"},
+						},
+						KVSecrets: KVSecrets{{
+							Path: "path/test",
+							Vars: KVSecret{{"KVSecret1", "kvsecret1"}},
+						}},
+						KV1Secrets: KV1Secrets{{
+							Path: "path2/test",
+							Vars: KVSecret{
+								{"KV1Secret1", "another one"},
+							},
+						}},
 					},
-					Secrets: Secrets{
-						"Secret1": "it's here",
+					Environments: map[string]Environment{
+						"dev": {Dcs: map[string]DC{"us-least-1": {}}},
 					},
-					KVSecrets: KVSecrets{{
-						Path: "path/test",
-						Vars: KVSecret{"KVSecret1": "kvsecret1"},
-					}},
-					KV1Secrets: KV1Secrets{{
-						Path: "path2/test",
-						Vars: KVSecret{
-							"KV1Secret1": "another one",
-						},
-					}},
 				},
 			},
 			want: OutputList{
@@ -638,7 +688,7 @@ func TestOutputList_Exec(t *testing.T) {
 	key := "BuildEnvTestKey"
 	val := "BuildEnvTestVal"
 	outputList := OutputList{
-		Output{key, val, "acomment"},
+		Output{Key: key, Value: val, Comment: "acomment"},
 	}
 
 	type fields struct {
@@ -648,7 +698,16 @@ func TestOutputList_Exec(t *testing.T) {
 	}
 
 	type args struct {
-		cmd string
+		cmd  string
+		opts []ExecOptFunc
+	}
+
+	inheritedKey := "BuildEnvTestInherited"
+	t.Setenv(inheritedKey, "parent")
+	unsetList := OutputList{
+		Output{Key: key, Value: val},
+		Output{Key: key, Unset: true},
+		Output{Key: inheritedKey, Unset: true},
 	}
 
 	tests := []struct {
@@ -697,6 +756,29 @@ func TestOutputList_Exec(t *testing.T) {
 			want:    val,
 			wantErr: 0,
 		},
+		{
+			name: "Unset removes earlier value",
+			args: args{
+				cmd: "echo -n ${" + key + "-none} ${" + inheritedKey + "-none}",
+			},
+			fields: fields{
+				Out: unsetList,
+			},
+			want:    "none parent",
+			wantErr: 0,
+		},
+		{
+			name: "Unset inherited value",
+			args: args{
+				cmd:  "echo -n ${" + key + "-none} ${" + inheritedKey + "-none}",
+				opts: []ExecOptFunc{WithUnsetInherited(true)},
+			},
+			fields: fields{
+				Out: unsetList,
+			},
+			want:    "none none",
+			wantErr: 0,
+		},
 	}
 
 	for _, tt := range tests {
@@ -704,7 +786,7 @@ func TestOutputList_Exec(t *testing.T) {
 			oldstdout := os.Stdout
 			r, w, _ := os.Pipe()
 			os.Stdout = w
-			got := tt.fields.Out.Exec(tt.args.cmd)
+			got := tt.fields.Out.Exec(tt.args.cmd, tt.args.opts...)
 
 			outC := make(chan string)
 
@@ -732,8 +814,8 @@ func TestOutputList_Exec(t *testing.T) {
 
 func TestOutputList_PrintB64Json(t *testing.T) {
 	envVars := EnvVars{
-		"BuildEnvTestKey1": "BuildEnvTestVal1",
-		"BuildEnvTestKey2": "BuildEnvTestVal2",
+		{"BuildEnvTestKey1", "BuildEnvTestVal1"},
+		{"BuildEnvTestKey2", "BuildEnvTestVal2"},
 	}
 
 	type fields struct {
@@ -751,6 +833,16 @@ func TestOutputList_PrintB64Json(t *testing.T) {
 			fields: fields{Out: envVars.GetOutput()},
 			want:   envVars,
 		},
+		{
+			name: "Overridden vars keep their first position",
+			fields: fields{Out: OutputList{
+				{Comment: "Global Variables"},
+				{Key: "B", Value: "1"},
+				{Key: "A", Value: "2"},
+				{Key: "B", Value: "3"},
+			}},
+			want: EnvVars{{"B", "3"}, {"A", "2"}},
+		},
 	}
 
 	for _, tt := range tests {
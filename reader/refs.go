@@ -0,0 +1,96 @@
+package reader
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// secret_ref_regexp matches inline secret references such as
+// vault://secret/app#key and ref+vault://secret/app#key. A reference starts
+// the value or follows whitespace, a quote, a colon or a slash, so values that
+// merely contain vault:// (myvault://, ?next=vault://) aren't references.
+var secret_ref_regexp = regexp.MustCompile(`(?:^|[\s"':/])((ref\+[A-Za-z0-9]+|vault)://([^\s#"'@]*)(?:#/?([A-Za-z0-9_.-]*))?)`)
+
+// SecretRef is a reference to a key at a Vault KV path found inside a value
+type SecretRef struct {
+	Path string
+	Key  string
+}
+
+func (s SecretRef) String() string {
+	return fmt.Sprintf("%s#%s", s.Path, s.Key)
+}
+
+// ParseSecretRefs finds the secret references in a value, returning the
+// index pairs of each reference alongside it. References to backends other
+// than Vault, or without a path or key, are errors.
+func ParseSecretRefs(value string) ([]SecretRef, [][]int, error) {
+	refs := []SecretRef{}
+	matches := secret_ref_regexp.FindAllStringSubmatchIndex(value, -1)
+	for i, match := range matches {
+		// Leave out the character before the reference
+		match = match[2:]
+		matches[i] = match
+		scheme := value[match[2]:match[3]]
+		ref := SecretRef{
+			Path: value[match[4]:match[5]],
+		}
+		if match[6] >= 0 {
+			ref.Key = value[match[6]:match[7]]
+		}
+		if scheme != "vault" && scheme != "ref+vault" {
+			return nil, nil, fmt.Errorf("unsupported secret reference %s: only vault:// and ref+vault:// are supported", value[match[0]:match[1]])
+		}
+		if ref.Path == "" || ref.Key == "" {
+			return nil, nil, fmt.Errorf("secret reference %s needs both a path and a #key", value[match[0]:match[1]])
+		}
+		refs = append(refs, ref)
+	}
+	return refs, matches, nil
+}
+
+// resolveSecretRefs substitutes inline secret references in plain values
+// with the values read from Vault. Values with references count as secrets,
+// so they're left out entirely when skipping Vault.
+func (r *Reader) resolveSecretRefs(ctx context.Context, vars OutputList, separators Separators) (OutputList, error) {
+	output := OutputList{}
+	for _, out := range vars {
+		refs, matches, err := ParseSecretRefs(out.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", out.Key, err)
+		}
+		if len(refs) == 0 {
+			output = append(output, out)
+			continue
+		}
+		if r.skipVault {
+			continue
+		}
+
+		value := &strings.Builder{}
+		last := 0
+		refNames := []string{}
+		for i, ref := range refs {
+			data, err := r.readKV(ctx, ref.Path, false)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", out.Key, err)
+			}
+			if _, hasValue := data[ref.Key]; !hasValue {
+				return nil, fmt.Errorf("%s: key %s not found in path %s", out.Key, ref.Key, ref.Path)
+			}
+			value.WriteString(out.Value[last:matches[i][0]])
+			value.WriteString(secretValue(data[ref.Key], separators.get(out.Key)))
+			last = matches[i][1]
+			refNames = append(refNames, ref.String())
+		}
+		value.WriteString(out.Value[last:])
+
+		out.Value = value.String()
+		out.Comment = fmt.Sprintf("References: %s", strings.Join(refNames, ", "))
+		out.Secret = true
+		output = append(output, out)
+	}
+	return output, nil
+}
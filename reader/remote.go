@@ -0,0 +1,130 @@
+package reader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// VaultScheme marks a variables definition kept in a Vault KV secret instead
+// of a file, e.g. vault://secret/buildenv/myapp?version=3#variables.yml. The
+// definition is read from the key after # ("value" by default), and a version
+// can pin it to one version of a kv2 secret.
+const VaultScheme = "vault://"
+
+// ErrRemoteNotAllowed is returned for definitions in Vault outside of the
+// allowed paths
+var ErrRemoteNotAllowed = errors.New("not in an allowed Vault path")
+
+// WithAllowedRemotes sets the Vault paths that definitions may be read from.
+// Each path allows itself and everything below it; with none, no definitions
+// are read from Vault.
+func WithAllowedRemotes(paths []string) ReaderOptFunc {
+	return func(r *Reader) {
+		r.allowedRemotes = paths
+	}
+}
+
+// LoadVariables reads variables files like the package's LoadVariables, and
+// reads definitions from Vault with the Reader's client
+func (r *Reader) LoadVariables(ctx context.Context, paths ...string) (*Variables, error) {
+	return (&loader{ctx: ctx, reader: r, strict: true, format: r.inputFormat}).loadFiles(paths)
+}
+
+func isRemote(path string) bool {
+	return strings.HasPrefix(path, VaultScheme)
+}
+
+// remoteDefinition is where in Vault a definition is kept
+type remoteDefinition struct {
+	path    string
+	key     string
+	version int
+}
+
+func parseRemote(location string) (remoteDefinition, error) {
+	remote := remoteDefinition{key: "value"}
+	rest := strings.TrimPrefix(location, VaultScheme)
+	rest, key, hasKey := strings.Cut(rest, "#")
+	if hasKey {
+		remote.key = key
+	}
+	rest, query, _ := strings.Cut(rest, "?")
+	remote.path = path.Clean(strings.Trim(rest, "/"))
+	if remote.path == "." || remote.key == "" {
+		return remote, fmt.Errorf("invalid definition %s: needs a path and key", location)
+	}
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return remote, fmt.Errorf("invalid definition %s: %w", location, err)
+	}
+	for name, values := range params {
+		if name != "version" || len(values) != 1 {
+			return remote, fmt.Errorf("invalid definition %s: unknown parameter %s", location, name)
+		}
+		remote.version, err = strconv.Atoi(values[0])
+		if err != nil || remote.version < 1 {
+			return remote, fmt.Errorf("invalid definition %s: version must be a positive number", location)
+		}
+	}
+	return remote, nil
+}
+
+// allowed checks whether the definition is at or below one of the paths
+func (d remoteDefinition) allowed(paths []string) bool {
+	for _, allowed := range paths {
+		allowed = path.Clean(strings.Trim(allowed, "/"))
+		if allowed == "." {
+			continue
+		}
+		if d.path == allowed || strings.HasPrefix(d.path, allowed+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// readDefinition reads a variables definition from Vault. The key can hold
+// YAML or JSON text, or the definition itself as structured data.
+func (r *Reader) readDefinition(ctx context.Context, location string) ([]byte, error) {
+	remote, err := parseRemote(location)
+	if err != nil {
+		return nil, err
+	}
+	if !remote.allowed(r.allowedRemotes) {
+		return nil, fmt.Errorf("unable to read %s: %w", location, ErrRemoteNotAllowed)
+	}
+	if r.skipVault {
+		return nil, fmt.Errorf("unable to read %s while skipping Vault", location)
+	}
+	data, err := r.readKVVersion(ctx, remote.path, false, remote.version)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", location, err)
+	}
+	value, found := data[remote.key]
+	if !found {
+		return nil, fmt.Errorf("unable to read %s: key %s not found in path %s", location, remote.key, remote.path)
+	}
+	if text, isText := value.(string); isText {
+		return []byte(text), nil
+	}
+	return json.Marshal(value)
+}
+
+// remoteInclude finds a file included by a definition in Vault, which is
+// another path in Vault: relative to the definition, or to the root if it
+// starts with /
+func remoteInclude(from string, include string) string {
+	if strings.HasPrefix(include, "/") {
+		return VaultScheme + strings.TrimPrefix(include, "/")
+	}
+	location := strings.TrimPrefix(from, VaultScheme)
+	location, _, _ = strings.Cut(location, "#")
+	location, _, _ = strings.Cut(location, "?")
+	return VaultScheme + path.Join(path.Dir(location), include)
+}
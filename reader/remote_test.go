@@ -0,0 +1,126 @@
+package reader
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/vault-client-go"
+)
+
+func TestReader_LoadVariablesRemote(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path + "?" + r.URL.RawQuery {
+		case "/v1/secret/data/buildenv/app?":
+			w.Write([]byte(`{"data":{"data":{"value":"include: [common]\nvars:\n  APP: latest\n"}}}`))
+		case "/v1/secret/data/buildenv/app?version=2":
+			w.Write([]byte(`{"data":{"data":{"value":"vars:\n  APP: pinned\n"}}}`))
+		case "/v1/secret/data/buildenv/common?":
+			w.Write([]byte(`{"data":{"data":{"value":"vars:\n  ORG: comcast\n  APP: common\n"}}}`))
+		case "/v1/secret/data/buildenv/json?":
+			w.Write([]byte(`{"data":{"data":{"definition":{"vars":{"FROM":"json"}}}}}`))
+		case "/v1/secret/data/other/app?":
+			t.Errorf("read a definition that isn't allowed")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		paths   []string
+		want    EnvVars
+		wantErr error
+	}{
+		{
+			name:  "Latest with an include",
+			paths: []string{"vault://secret/buildenv/app"},
+			want:  EnvVars{{"ORG", "comcast"}, {"APP", "latest"}},
+		},
+		{
+			name:  "Pinned version",
+			paths: []string{"vault://secret/buildenv/app?version=2"},
+			want:  EnvVars{{"APP", "pinned"}},
+		},
+		{
+			name:  "Structured definition in a key",
+			paths: []string{"vault://secret/buildenv/json#definition"},
+			want:  EnvVars{{"FROM", "json"}},
+		},
+		{
+			name:  "Optional missing definition",
+			paths: []string{"vault://secret/buildenv/app?version=2", "vault://secret/buildenv/nope?"},
+			want:  EnvVars{{"APP", "pinned"}},
+		},
+		{
+			name:    "Missing definition",
+			paths:   []string{"vault://secret/buildenv/nope"},
+			wantErr: ErrSecretNotFound,
+		},
+		{
+			name:    "Not allowed",
+			paths:   []string{"vault://secret/other/app"},
+			wantErr: ErrRemoteNotAllowed,
+		},
+		{
+			name:    "Escaping the allowed path",
+			paths:   []string{"vault://secret/buildenv/../other/app"},
+			wantErr: ErrRemoteNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := vault.New(vault.WithAddress(server.URL))
+			reader := &Reader{client: client, allowedRemotes: []string{"/secret/buildenv/"}}
+			got, err := reader.LoadVariables(context.Background(), tt.paths...)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Reader.LoadVariables() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Reader.LoadVariables() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.Vars, tt.want) {
+				t.Errorf("Reader.LoadVariables() vars = %v, want %v", got.Vars, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRemote(t *testing.T) {
+	tests := []struct {
+		location string
+		want     remoteDefinition
+		wantErr  bool
+	}{
+		{location: "vault://secret/app", want: remoteDefinition{path: "secret/app", key: "value"}},
+		{location: "vault://secret/app?version=3#variables.yml", want: remoteDefinition{path: "secret/app", key: "variables.yml", version: 3}},
+		{location: "vault://secret/app?version=0", wantErr: true},
+		{location: "vault://secret/app?ref=main", wantErr: true},
+		{location: "vault://secret/app#", wantErr: true},
+		{location: "vault://", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseRemote(tt.location)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseRemote(%q) error = %v, wantErr %v", tt.location, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("parseRemote(%q) = %+v, want %+v", tt.location, got, tt.want)
+		}
+	}
+}
+
+func TestLoadVariables_RemoteNeedsReader(t *testing.T) {
+	if _, err := LoadVariables("vault://secret/app"); err == nil {
+		t.Errorf("LoadVariables() of a definition in Vault should fail without a Reader")
+	}
+}
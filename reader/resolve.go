@@ -0,0 +1,68 @@
+package reader
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Resolve collapses variables that are set more than once, keeping the last
+// definition, since later scopes take precedence: global, then environment,
+// then datacenter, then values given on the command line. Each remaining
+// variable records the scope it came from, and the scopes it overrode are
+// added to its comment. A variable unset last is kept as an unset entry.
+// With strict, any override is an error instead, except for an unset, which
+// is always deliberate.
+func (o OutputList) Resolve(strict bool) (OutputList, error) {
+	lastDef := map[string]int{}
+	scopes := make([]string, len(o))
+	section := ""
+	for i, out := range o {
+		if out.Key == "" {
+			section = out.Comment
+			continue
+		}
+		scopes[i] = section
+		lastDef[out.Key] = i
+	}
+
+	overrides := map[string][]string{}
+	conflicts := []string{}
+	for i, out := range o {
+		if out.Key != "" && lastDef[out.Key] != i {
+			if len(overrides[out.Key]) == 0 {
+				conflicts = append(conflicts, out.Key)
+			}
+			overrides[out.Key] = append(overrides[out.Key], scopes[i])
+		}
+	}
+	if strict {
+		details := []string{}
+		for _, key := range conflicts {
+			if o[lastDef[key]].Unset {
+				continue
+			}
+			details = append(details, fmt.Sprintf("%s (%s, %s)", key, strings.Join(overrides[key], ", "), scopes[lastDef[key]]))
+		}
+		if len(details) > 0 {
+			return nil, fmt.Errorf("variables set in more than one scope: %s", strings.Join(details, "; "))
+		}
+	}
+
+	resolved := OutputList{}
+	for i, out := range o {
+		if out.Key == "" {
+			resolved = append(resolved, out)
+			continue
+		}
+		if lastDef[out.Key] != i {
+			continue
+		}
+		out.Scope = scopes[i]
+		out.Overrides = overrides[out.Key]
+		if len(out.Overrides) > 0 {
+			out.Comment = joinComment(out.Comment, fmt.Sprintf("Overrides: %s", strings.Join(out.Overrides, " and ")))
+		}
+		resolved = append(resolved, out)
+	}
+	return resolved, nil
+}
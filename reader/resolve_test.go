@@ -0,0 +1,108 @@
+package reader
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestOutputList_Resolve(t *testing.T) {
+	input := OutputList{
+		{Comment: "Global Variables"},
+		{Key: "HOST", Value: "global"},
+		{Key: "PORT", Value: "80"},
+		{Comment: "Environment: stage"},
+		{Key: "HOST", Value: "stage"},
+		{Comment: "Datacenter: east"},
+		{Key: "HOST", Value: "east", Comment: "Path: secret/east, Key: host", Secret: true},
+		{Comment: "Command Line"},
+		{Key: "PORT", Value: "8080"},
+	}
+
+	want := OutputList{
+		{Comment: "Global Variables"},
+		{Comment: "Environment: stage"},
+		{Comment: "Datacenter: east"},
+		{
+			Key:       "HOST",
+			Value:     "east",
+			Comment:   "Path: secret/east, Key: host, Overrides: Global Variables and Environment: stage",
+			Secret:    true,
+			Scope:     "Datacenter: east",
+			Overrides: []string{"Global Variables", "Environment: stage"},
+		},
+		{Comment: "Command Line"},
+		{
+			Key:       "PORT",
+			Value:     "8080",
+			Comment:   "Overrides: Global Variables",
+			Scope:     "Command Line",
+			Overrides: []string{"Global Variables"},
+		},
+	}
+	got, err := input.Resolve(false)
+	if err != nil {
+		t.Fatalf("OutputList.Resolve() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("OutputList.Resolve() = %v, want %v", got, want)
+	}
+
+	_, err = input.Resolve(true)
+	wantErr := "variables set in more than one scope: HOST (Global Variables, Environment: stage, Datacenter: east); PORT (Global Variables, Command Line)"
+	if err == nil || err.Error() != wantErr {
+		t.Errorf("OutputList.Resolve(strict) error = %v, want %v", err, wantErr)
+	}
+
+	unique := OutputList{{Comment: "Global Variables"}, {Key: "A", Value: "a"}}
+	if got, err := unique.Resolve(true); err != nil || !reflect.DeepEqual(got, OutputList{{Comment: "Global Variables"}, {Key: "A", Value: "a", Scope: "Global Variables"}}) {
+		t.Errorf("OutputList.Resolve(strict) = %v, %v", got, err)
+	}
+}
+
+func TestReader_ReadUnset(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"variables.yml": `
+vars:
+  HTTP_PROXY: "http://proxy"
+  LOG_LEVEL: "info"
+environments:
+  base:
+    vars:
+      DEBUG: "true"
+  stage:
+    extends: base
+    unset: [DEBUG]
+    dcs:
+      east:
+        unset: [HTTP_PROXY]
+`,
+	})
+	file := filepath.Join(dir, "variables.yml")
+	input, err := LoadVariables(file)
+	if err != nil {
+		t.Fatalf("LoadVariables() error = %v", err)
+	}
+	reader, _ := NewReader(WithSkipVault(true))
+	out, err := reader.Read(context.Background(), input, "stage", "east")
+	if err != nil {
+		t.Fatalf("Reader.Read() error = %v", err)
+	}
+	got, err := out.Resolve(true)
+	if err != nil {
+		t.Fatalf("OutputList.Resolve() error = %v", err)
+	}
+
+	want := OutputList{
+		{Comment: "Global Variables"},
+		{Key: "LOG_LEVEL", Value: "info", Origin: file, Scope: "Global Variables"},
+		{Comment: "Environment: stage"},
+		{Key: "DEBUG", Unset: true, Origin: file, Scope: "Environment: stage"},
+		{Comment: "Datacenter: east"},
+		{Key: "HTTP_PROXY", Unset: true, Origin: file, Comment: "Overrides: Global Variables", Scope: "Datacenter: east", Overrides: []string{"Global Variables"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Reader.Read() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,361 @@
+package reader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The dimensions of the environments and dcs sections
+const (
+	EnvironmentDimension = "environment"
+	DCDimension          = "dc"
+)
+
+// Scope holds the values for one name of a dimension, e.g. region us-east,
+// and can hold further scopes of later dimensions
+type Scope struct {
+	scope  `yaml:",inline"`
+	Scopes Scopes `yaml:"scopes,omitempty"`
+}
+
+// Scopes holds scopes by dimension, then name, e.g. scopes["region"]["us-east"]
+type Scopes map[string]map[string]Scope
+
+func (s *Scope) UnmarshalYAML(node *yaml.Node) error {
+	type plain Scope
+	if err := node.Decode((*plain)(s)); err != nil {
+		return err
+	}
+	return s.finishDecode(node)
+}
+
+// Selection is the scope name chosen for each dimension, e.g.
+// {"environment": "prod", "region": "us-east"}
+type Selection map[string]string
+
+// ParseSelection reads dimension=name pairs, as given to --scope
+func ParseSelection(pairs []string) (Selection, error) {
+	selection := Selection{}
+	for _, pair := range pairs {
+		dimension, name, found := strings.Cut(pair, "=")
+		if !found || dimension == "" || name == "" {
+			return nil, fmt.Errorf("scope %q should be dimension=name", pair)
+		}
+		if existing, found := selection[dimension]; found && existing != name {
+			return nil, fmt.Errorf("scope %s selected as both %s and %s", dimension, existing, name)
+		}
+		selection[dimension] = name
+	}
+	return selection, nil
+}
+
+// dimensions returns the declared dimensions in order of precedence, lowest
+// first. The environment and dc dimensions come first unless declared.
+func (v Variables) dimensions() ([]string, error) {
+	dimensions := []string{}
+	for _, dimension := range []string{EnvironmentDimension, DCDimension} {
+		if !slices.Contains(v.Dimensions, dimension) {
+			dimensions = append(dimensions, dimension)
+		}
+	}
+	for _, dimension := range v.Dimensions {
+		if slices.Contains(dimensions, dimension) {
+			return nil, fmt.Errorf("dimension %s is declared more than once", dimension)
+		}
+		dimensions = append(dimensions, dimension)
+	}
+	if slices.Index(dimensions, DCDimension) < slices.Index(dimensions, EnvironmentDimension) {
+		return nil, fmt.Errorf("dimension %s must come before %s", EnvironmentDimension, DCDimension)
+	}
+	return dimensions, nil
+}
+
+// scopeNode is a scope in the tree below the global scope. Environments are
+// scopes of the environment dimension and their dcs scopes of the dc
+// dimension, alongside the scopes declared under scopes.
+type scopeNode struct {
+	dimension string
+	name      string
+	path      string
+	// label names environments and datacenters in comments, other scopes are
+	// described by their dimension
+	label    string
+	scope    scope
+	children []scopeNode
+}
+
+// tree returns the global scope with every scope below it
+func (v Variables) tree() scopeNode {
+	root := scopeNode{scope: v.scope, children: scopeNodes("", v.Scopes)}
+	for _, envName := range sortedKeys(v.Environments) {
+		env := v.Environments[envName]
+		envNode := scopeNode{
+			dimension: EnvironmentDimension,
+			name:      envName,
+			path:      fmt.Sprintf("environments.%s", envName),
+			label:     "Environment",
+			scope:     env.scope,
+		}
+		envNode.children = scopeNodes(envNode.path, env.Scopes)
+		for _, dcName := range sortedKeys(env.Dcs) {
+			dcNode := scopeNode{
+				dimension: DCDimension,
+				name:      dcName,
+				path:      fmt.Sprintf("%s.dcs.%s", envNode.path, dcName),
+				label:     "Datacenter",
+				scope:     env.Dcs[dcName].scope,
+			}
+			dcNode.children = scopeNodes(dcNode.path, env.Dcs[dcName].Scopes)
+			envNode.children = append(envNode.children, dcNode)
+		}
+		root.children = append(root.children, envNode)
+	}
+	return root
+}
+
+// scopeNodes lists the scopes declared under parent, and the scopes below them
+func scopeNodes(parent string, scopes Scopes) []scopeNode {
+	nodes := []scopeNode{}
+	for _, dimension := range sortedKeys(scopes) {
+		for _, name := range sortedKeys(scopes[dimension]) {
+			path := fmt.Sprintf("scopes.%s.%s", dimension, name)
+			if parent != "" {
+				path = parent + "." + path
+			}
+			nodes = append(nodes, scopeNode{
+				dimension: dimension,
+				name:      name,
+				path:      path,
+				scope:     scopes[dimension][name].scope,
+				children:  scopeNodes(path, scopes[dimension][name].Scopes),
+			})
+		}
+	}
+	return nodes
+}
+
+// walk calls fn for the node and every node below it, with the nodes above it
+func (n scopeNode) walk(ancestors []scopeNode, fn func(node scopeNode, ancestors []scopeNode)) {
+	fn(n, ancestors)
+	ancestors = append(slices.Clip(ancestors), n)
+	for _, child := range n.children {
+		child.walk(ancestors, fn)
+	}
+}
+
+// comment describes the node when it's selected by name
+func (n scopeNode) comment(name string) string {
+	switch {
+	case n.path == "":
+		return "Global Variables"
+	case n.label == "":
+		return fmt.Sprintf("Scope: %s=%s", n.dimension, n.name)
+	case name != n.name:
+		return fmt.Sprintf("%s: %s (%s)", n.label, name, n.name)
+	}
+	return fmt.Sprintf("%s: %s", n.label, n.name)
+}
+
+// selectedScope is a scope that applies to a selection
+type selectedScope struct {
+	comment   string
+	path      string
+	dimension int
+	depth     int
+	scope     scope
+}
+
+// selectScopes finds the scopes that apply to a selection, ordered so that
+// later scopes take precedence: scopes of later dimensions win, and among
+// scopes of the same dimension, the more deeply nested one wins.
+func (v Variables) selectScopes(selection Selection) ([]selectedScope, error) {
+	dimensions, err := v.dimensions()
+	if err != nil {
+		return nil, err
+	}
+	for _, dimension := range sortedKeys(selection) {
+		if selection[dimension] != "" && !slices.Contains(dimensions, dimension) {
+			return nil, fmt.Errorf("unknown dimension %s, expected one of: %s", dimension, strings.Join(dimensions, ", "))
+		}
+	}
+
+	// A name that no scope has is likely a typo. Environments and datacenters
+	// are checked when the selection is resolved.
+	root := v.tree()
+	names := map[string][]string{}
+	root.walk(nil, func(node scopeNode, _ []scopeNode) {
+		if node.label == "" && node.path != "" && !slices.Contains(names[node.dimension], node.name) {
+			names[node.dimension] = append(names[node.dimension], node.name)
+		}
+	})
+	for _, dimension := range sortedKeys(selection) {
+		name := selection[dimension]
+		if name == "" || dimension == EnvironmentDimension || dimension == DCDimension {
+			continue
+		}
+		if !slices.Contains(names[dimension], name) {
+			available := names[dimension]
+			slices.Sort(available)
+			return nil, fmt.Errorf("%w: %s", ErrInvalidSelection, unknownName(dimension, name, available))
+		}
+	}
+
+	selected := []selectedScope{{
+		comment:   root.comment(""),
+		dimension: -1,
+		scope:     root.scope,
+	}}
+	var walk func(parent selectedScope, children []scopeNode) error
+	walk = func(parent selectedScope, children []scopeNode) error {
+		// Datacenters can be patterns, and only the best match applies
+		patterns := []string{}
+		for _, child := range children {
+			if child.label == "Datacenter" {
+				patterns = append(patterns, child.name)
+			}
+		}
+		var dcName string
+		if len(patterns) > 0 && selection[DCDimension] != "" {
+			var err error
+			if dcName, err = matchDC(patterns, selection[DCDimension]); err != nil {
+				return fmt.Errorf("%w: %w", ErrInvalidSelection, err)
+			}
+		}
+
+		for _, child := range children {
+			rank := slices.Index(dimensions, child.dimension)
+			if rank < 0 {
+				return fmt.Errorf("%s: unknown dimension %s", strings.TrimSuffix(child.path, "."+child.name), child.dimension)
+			}
+			if rank <= parent.dimension {
+				return fmt.Errorf("%s: %s must be nested in scopes of earlier dimensions", strings.TrimSuffix(child.path, "."+child.name), child.dimension)
+			}
+			want := selection[child.dimension]
+			if child.label == "Datacenter" {
+				want = dcName
+			}
+			if want == "" || child.name != want {
+				continue
+			}
+			node := selectedScope{
+				comment:   child.comment(selection[child.dimension]),
+				path:      child.path,
+				dimension: rank,
+				depth:     parent.depth + 1,
+				scope:     child.scope,
+			}
+			selected = append(selected, node)
+			if err := walk(node, child.children); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(selected[0], root.children); err != nil {
+		return nil, err
+	}
+
+	slices.SortStableFunc(selected, func(a, b selectedScope) int {
+		if a.dimension != b.dimension {
+			return a.dimension - b.dimension
+		}
+		return a.depth - b.depth
+	})
+	return selected, nil
+}
+
+// ReadScopes reads the global values followed by those of every scope that
+// applies to the selection, in order of precedence
+func (r *Reader) ReadScopes(ctx context.Context, input *Variables, selection Selection) (OutputList, error) {
+	selection, err := input.resolveSelection(selection)
+	if err != nil {
+		return nil, err
+	}
+	selected, err := input.selectScopes(selection)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkEnvironment(selected); err != nil {
+		return nil, err
+	}
+
+	output := OutputList{}
+	data := TemplateData(selection)
+	for _, node := range selected {
+		output = append(output, Output{
+			Comment: node.comment,
+		})
+		s := sourcedScope{
+			scope:     node.scope,
+			file:      input.File,
+			path:      node.path,
+			origins:   input.Origins,
+			inherited: input.Inherited,
+		}
+		scopeOut, err := r.readScope(ctx, s, data)
+		if err != nil {
+			return nil, err
+		}
+		output = append(output, scopeOut...)
+	}
+	if len(r.commandLine) > 0 {
+		output = append(output, Output{Comment: "Command Line"})
+		output = append(output, r.commandLine...)
+	}
+
+	// Interpolation comes last, once secret references are resolved and the
+	// command line values are added
+	if r.interpolate {
+		var lookupEnv func(string) (string, bool)
+		if r.hostEnv {
+			lookupEnv = os.LookupEnv
+		}
+		return output.Interpolate(lookupEnv)
+	}
+
+	return output, nil
+}
+
+// mergeScopeTrees merges scopes by dimension and name, using the same rules
+// as the rest of the variables
+func mergeScopeTrees(base Scopes, top Scopes) Scopes {
+	if base == nil && top == nil {
+		return nil
+	}
+	merged := Scopes{}
+	for dimension, scopes := range base {
+		merged[dimension] = mergeMaps(nil, scopes)
+	}
+	for dimension, scopes := range top {
+		if merged[dimension] == nil {
+			merged[dimension] = map[string]Scope{}
+		}
+		for name, s := range scopes {
+			existing := merged[dimension][name]
+			merged[dimension][name] = Scope{
+				scope:  mergeScopes(existing.scope, s.scope),
+				Scopes: mergeScopeTrees(existing.Scopes, s.Scopes),
+			}
+		}
+	}
+	return merged
+}
+
+// addOrigins records the file for the values of every scope in the tree
+func (s Scopes) addOrigins(origins map[string]string, path string, file string) {
+	for dimension, scopes := range s {
+		for name, child := range scopes {
+			childPath := fmt.Sprintf("scopes.%s.%s", dimension, name)
+			if path != "" {
+				childPath = path + "." + childPath
+			}
+			child.scope.addOrigins(origins, childPath, file)
+			child.Scopes.addOrigins(origins, childPath, file)
+		}
+	}
+}
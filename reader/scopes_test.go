@@ -0,0 +1,161 @@
+package reader
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const scopesYAML = `
+dimensions: [environment, dc, region, cluster]
+vars:
+  LEVEL: "global"
+scopes:
+  region:
+    us-east:
+      vars:
+        LEVEL: "region"
+        REGION: "us-east-1"
+      scopes:
+        cluster:
+          blue:
+            vars:
+              CLUSTER: "blue"
+environments:
+  prod:
+    vars:
+      LEVEL: "prod"
+    scopes:
+      region:
+        us-east:
+          vars:
+            REPLICAS: "6"
+    dcs:
+      ndc:
+        vars:
+          DC: "ndc"
+`
+
+func TestReader_ReadScopes(t *testing.T) {
+	var input Variables
+	if err := yaml.Unmarshal([]byte(scopesYAML), &input); err != nil {
+		t.Fatalf("yaml.Unmarshal() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		selection Selection
+		want      OutputList
+		wantErr   string
+	}{
+		{
+			name:      "legacy",
+			selection: Selection{EnvironmentDimension: "prod", DCDimension: "ndc"},
+			want: OutputList{
+				{Comment: "Global Variables"},
+				{Key: "LEVEL", Value: "global"},
+				{Comment: "Environment: prod"},
+				{Key: "LEVEL", Value: "prod"},
+				{Comment: "Datacenter: ndc"},
+				{Key: "DC", Value: "ndc"},
+			},
+		},
+		{
+			name:      "nested",
+			selection: Selection{EnvironmentDimension: "prod", "region": "us-east", "cluster": "blue"},
+			want: OutputList{
+				{Comment: "Global Variables"},
+				{Key: "LEVEL", Value: "global"},
+				{Comment: "Environment: prod"},
+				{Key: "LEVEL", Value: "prod"},
+				{Comment: "Scope: region=us-east"},
+				{Key: "LEVEL", Value: "region"},
+				{Key: "REGION", Value: "us-east-1"},
+				{Comment: "Scope: region=us-east"},
+				{Key: "REPLICAS", Value: "6"},
+				{Comment: "Scope: cluster=blue"},
+				{Key: "CLUSTER", Value: "blue"},
+			},
+		},
+		{
+			name:      "unselected parent",
+			selection: Selection{"cluster": "blue"},
+			want: OutputList{
+				{Comment: "Global Variables"},
+				{Key: "LEVEL", Value: "global"},
+			},
+		},
+		{
+			name:      "misspelled name",
+			selection: Selection{EnvironmentDimension: "prod", "region": "us-eastt"},
+			wantErr:   "invalid selection: unknown region us-eastt, did you mean us-east? Available: us-east",
+		},
+		{
+			name:      "unknown name",
+			selection: Selection{"cluster": "green"},
+			wantErr:   "invalid selection: unknown cluster green. Available: blue",
+		},
+		{
+			name:      "unknown dimension",
+			selection: Selection{"zone": "a"},
+			wantErr:   "unknown dimension zone",
+		},
+	}
+	reader, _ := NewReader(WithSkipVault(true))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := reader.ReadScopes(context.Background(), &input, tt.selection)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Reader.ReadScopes() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Reader.ReadScopes() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Reader.ReadScopes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReader_ReadScopesNesting(t *testing.T) {
+	input := &Variables{
+		Dimensions: []string{"region", "cluster"},
+		Scopes: Scopes{
+			"cluster": {
+				"blue": Scope{
+					Scopes: Scopes{
+						"region": {"us-east": Scope{}},
+					},
+				},
+			},
+		},
+	}
+	reader, _ := NewReader(WithSkipVault(true))
+	_, err := reader.ReadScopes(context.Background(), input, Selection{"cluster": "blue"})
+	want := "scopes.cluster.blue.scopes.region: region must be nested in scopes of earlier dimensions"
+	if err == nil || err.Error() != want {
+		t.Errorf("Reader.ReadScopes() error = %v, want %v", err, want)
+	}
+}
+
+func TestParseSelection(t *testing.T) {
+	got, err := ParseSelection([]string{"region=us-east", "cluster=blue"})
+	if err != nil {
+		t.Fatalf("ParseSelection() error = %v", err)
+	}
+	if want := (Selection{"region": "us-east", "cluster": "blue"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseSelection() = %v, want %v", got, want)
+	}
+	for _, bad := range [][]string{{"region"}, {"=us-east"}, {"region=a", "region=b"}} {
+		if _, err := ParseSelection(bad); err == nil {
+			t.Errorf("ParseSelection(%v) should fail", bad)
+		}
+	}
+}
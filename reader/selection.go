@@ -0,0 +1,145 @@
+package reader
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// ErrInvalidSelection is returned when the selected environment or datacenter
+// isn't in the variables
+var ErrInvalidSelection = errors.New("invalid selection")
+
+// resolveSelection fills in the file's default environment and datacenter,
+// and makes sure the environment and datacenter selected exist
+func (v Variables) resolveSelection(selection Selection) (Selection, error) {
+	resolved := Selection{}
+	for dimension, name := range selection {
+		resolved[dimension] = name
+	}
+	env, dc := resolved[EnvironmentDimension], resolved[DCDimension]
+	if env == "" && v.DefaultEnvironment != "" {
+		env = v.DefaultEnvironment
+		resolved[EnvironmentDimension] = env
+	}
+	if env == "" {
+		if dc != "" {
+			return nil, fmt.Errorf("%w: datacenter %s needs an environment", ErrInvalidSelection, dc)
+		}
+		return resolved, nil
+	}
+
+	environment, found := v.Environments[env]
+	if !found {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSelection, unknownName("environment", env, sortedKeys(v.Environments)))
+	}
+	if dc == "" {
+		// The default only applies to environments that have it
+		if _, err := environment.findDC(v.DefaultDC); v.DefaultDC != "" && err == nil {
+			resolved[DCDimension] = v.DefaultDC
+		}
+		return resolved, nil
+	}
+	if _, err := environment.findDC(dc); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSelection, err)
+	}
+	return resolved, nil
+}
+
+// findDC finds the datacenter for a name: the one with that name, or else
+// the longest glob pattern, such as us-*, that matches it
+func (e Environment) findDC(name string) (string, error) {
+	return matchDC(sortedKeys(e.Dcs), name)
+}
+
+// matchDC finds the datacenter for a name among the names and patterns of an
+// environment's datacenters
+func matchDC(dcs []string, name string) (string, error) {
+	if slices.Contains(dcs, name) {
+		return name, nil
+	}
+	matched := []string{}
+	for _, pattern := range dcs {
+		if isMatch, err := path.Match(pattern, name); err != nil {
+			return "", fmt.Errorf("datacenter %s: %w", pattern, err)
+		} else if isMatch {
+			matched = append(matched, pattern)
+		}
+	}
+	if len(matched) == 0 {
+		return "", errors.New(unknownName("datacenter", name, dcs))
+	}
+	// Only a tie between the longest patterns is ambiguous
+	longest := 0
+	for _, pattern := range matched {
+		longest = max(longest, len(pattern))
+	}
+	best := ""
+	for _, pattern := range matched {
+		if len(pattern) != longest {
+			continue
+		}
+		if best != "" {
+			return "", fmt.Errorf("datacenter %s matches both %s and %s", name, best, pattern)
+		}
+		best = pattern
+	}
+	return best, nil
+}
+
+// unknownName describes a name that wasn't found, with the closest of the
+// names available if it looks like a typo
+func unknownName(what string, name string, available []string) string {
+	if len(available) == 0 {
+		return fmt.Sprintf("unknown %s %s, there are none", what, name)
+	}
+	message := fmt.Sprintf("unknown %s %s.", what, name)
+	if suggestion := closest(name, available); suggestion != "" {
+		message = fmt.Sprintf("unknown %s %s, did you mean %s?", what, name, suggestion)
+	}
+	return message + fmt.Sprintf(" Available: %s", strings.Join(available, ", "))
+}
+
+// closest finds the name nearest to a misspelled one, if any is near enough
+func closest(name string, names []string) string {
+	best, bestDistance := "", 0
+	for _, candidate := range names {
+		distance := editDistance(strings.ToLower(name), strings.ToLower(candidate))
+		if best == "" || distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	if bestDistance <= 2 && bestDistance < len(name) || bestDistance <= len(name)/3 {
+		return best
+	}
+	return ""
+}
+
+// editDistance counts the insertions, deletions, substitutions and swaps of
+// adjacent characters that turn a into b
+func editDistance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
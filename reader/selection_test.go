@@ -0,0 +1,185 @@
+package reader
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const selectionYAML = `
+default_environment: dev
+default_dc: local
+environments:
+  dev:
+    dcs:
+      local:
+        vars:
+          DC: "local"
+  stage:
+    vars:
+      ENV: "stage"
+    dcs:
+      us-*:
+        vars:
+          DC: "us"
+      us-east-*:
+        vars:
+          DC: "us-east"
+      eu-west-1:
+        vars:
+          DC: "eu-west-1"
+  prod:
+    dcs:
+      "*-1":
+        vars:
+          DC: "one"
+      "us*":
+        vars:
+          DC: "us"
+      "u*1":
+        vars:
+          DC: "u1"
+      "us-*1":
+        vars:
+          DC: "us-1"
+`
+
+func TestReader_ReadSelection(t *testing.T) {
+	var input Variables
+	if err := yaml.Unmarshal([]byte(selectionYAML), &input); err != nil {
+		t.Fatalf("yaml.Unmarshal() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		env     string
+		dc      string
+		want    OutputList
+		wantErr string
+	}{
+		{
+			name: "defaults",
+			want: OutputList{
+				{Comment: "Global Variables"},
+				{Comment: "Environment: dev"},
+				{Comment: "Datacenter: local"},
+				{Key: "DC", Value: "local"},
+			},
+		},
+		{
+			name: "default datacenter the environment doesn't have",
+			env:  "stage",
+			want: OutputList{
+				{Comment: "Global Variables"},
+				{Comment: "Environment: stage"},
+				{Key: "ENV", Value: "stage"},
+			},
+		},
+		{
+			name: "exact name before patterns",
+			env:  "stage",
+			dc:   "eu-west-1",
+			want: OutputList{
+				{Comment: "Global Variables"},
+				{Comment: "Environment: stage"},
+				{Key: "ENV", Value: "stage"},
+				{Comment: "Datacenter: eu-west-1"},
+				{Key: "DC", Value: "eu-west-1"},
+			},
+		},
+		{
+			name: "longest pattern",
+			env:  "stage",
+			dc:   "us-east-1",
+			want: OutputList{
+				{Comment: "Global Variables"},
+				{Comment: "Environment: stage"},
+				{Key: "ENV", Value: "stage"},
+				{Comment: "Datacenter: us-east-1 (us-east-*)"},
+				{Key: "DC", Value: "us-east"},
+			},
+		},
+		{
+			name: "longest pattern beats tied shorter ones",
+			env:  "prod",
+			dc:   "us-east-1",
+			want: OutputList{
+				{Comment: "Global Variables"},
+				{Comment: "Environment: prod"},
+				{Comment: "Datacenter: us-east-1 (us-*1)"},
+				{Key: "DC", Value: "us-1"},
+			},
+		},
+		{
+			name:    "ambiguous patterns",
+			env:     "prod",
+			dc:      "us1",
+			wantErr: "invalid selection: datacenter us1 matches both u*1 and us*",
+		},
+		{
+			name:    "misspelled environment",
+			env:     "stgae",
+			wantErr: "invalid selection: unknown environment stgae, did you mean stage? Available: dev, prod, stage",
+		},
+		{
+			name:    "unknown environment",
+			env:     "qa",
+			wantErr: "invalid selection: unknown environment qa. Available: dev, prod, stage",
+		},
+		{
+			name:    "misspelled datacenter",
+			env:     "stage",
+			dc:      "eu-wset-1",
+			wantErr: "invalid selection: unknown datacenter eu-wset-1, did you mean eu-west-1? Available: eu-west-1, us-*, us-east-*",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, _ := NewReader(WithSkipVault(true))
+			got, err := reader.Read(context.Background(), &input, tt.env, tt.dc)
+			if tt.wantErr != "" {
+				if !errors.Is(err, ErrInvalidSelection) || err.Error() != tt.wantErr {
+					t.Errorf("Reader.Read() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Reader.Read() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Reader.Read() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReader_ReadDCWithoutEnvironment(t *testing.T) {
+	input := &Variables{Environments: map[string]Environment{"dev": {Dcs: map[string]DC{"local": {}}}}}
+	reader, _ := NewReader(WithSkipVault(true))
+	_, err := reader.Read(context.Background(), input, "", "local")
+	want := "invalid selection: datacenter local needs an environment"
+	if !errors.Is(err, ErrInvalidSelection) || err.Error() != want {
+		t.Errorf("Reader.Read() error = %v, want %v", err, want)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"stage", "stage", 0},
+		{"stgae", "stage", 1},
+		{"prd", "prod", 1},
+		{"", "dev", 3},
+		{"ndc_one", "ndc_two", 3},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
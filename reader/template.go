@@ -0,0 +1,106 @@
+package reader
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// WithTemplates renders vars values as Go text/template templates
+func WithTemplates(enabled bool) ReaderOptFunc {
+	return func(r *Reader) {
+		r.templates = enabled
+	}
+}
+
+// TemplateFuncs are the functions available to templated values
+var TemplateFuncs = template.FuncMap{
+	"env": os.Getenv,
+	"default": func(def string, val ...interface{}) string {
+		if len(val) == 0 || val[0] == nil || fmt.Sprint(val[0]) == "" {
+			return def
+		}
+		return fmt.Sprint(val[0])
+	},
+	"b64enc": func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	},
+	"b64dec": func(s string) (string, error) {
+		decoded, err := base64.StdEncoding.DecodeString(s)
+		return string(decoded), err
+	},
+	"sha256sum": func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	},
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+	"trim":       strings.TrimSpace,
+	"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+	"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"quote":      strconv.Quote,
+	"now":        time.Now,
+	"date":       func(layout string, t time.Time) string { return t.Format(layout) },
+}
+
+// TemplateData is what templated values can refer to, e.g. {{ .Environment }}.
+// Other dimensions are available by name, e.g. {{ .region }}.
+func TemplateData(selection Selection) map[string]string {
+	data := map[string]string{
+		"Environment": selection[EnvironmentDimension],
+		"DC":          selection[DCDimension],
+	}
+	for dimension, name := range selection {
+		if dimension != EnvironmentDimension && dimension != DCDimension {
+			data[dimension] = name
+		}
+	}
+	return data
+}
+
+// renderTemplates renders the values of vars that contain template actions.
+// Errors name the file and the key of the value.
+func renderTemplates(vars OutputList, s sourcedScope, data map[string]string) (OutputList, error) {
+	output := OutputList{}
+	for _, out := range vars {
+		if strings.Contains(out.Value, "{{") {
+			name := "vars." + out.Key
+			if s.path != "" {
+				name = s.path + "." + name
+			}
+			rendered, err := renderTemplate(name, out.Value, data)
+			if err != nil {
+				file := s.file
+				if origin, found := s.origins[name]; found {
+					file = origin
+				}
+				if file != "" {
+					return nil, fmt.Errorf("%s: %w", file, err)
+				}
+				return nil, err
+			}
+			out.Value = rendered
+		}
+		output = append(output, out)
+	}
+	return output, nil
+}
+
+func renderTemplate(name string, text string, data map[string]string) (string, error) {
+	tmpl, err := template.New(name).Funcs(TemplateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	rendered := &strings.Builder{}
+	if err := tmpl.Execute(rendered, data); err != nil {
+		return "", err
+	}
+	return rendered.String(), nil
+}
@@ -0,0 +1,90 @@
+package reader
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	t.Setenv("BUILDENV_TEMPLATE_TEST", "from-env")
+	data := TemplateData(Selection{EnvironmentDimension: "Stage", DCDimension: "us-east-1"})
+
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr bool
+	}{
+		{name: "Env", text: `{{ env "BUILDENV_TEMPLATE_TEST" }}`, want: "from-env"},
+		{name: "Data", text: `{{ .Environment }}/{{ .DC }}`, want: "Stage/us-east-1"},
+		{name: "Default Missing", text: `{{ default "x" .Y }}`, want: "x"},
+		{name: "Default Present", text: `{{ default "x" .DC }}`, want: "us-east-1"},
+		{name: "Pipes", text: `{{ .Environment | lower }}-{{ "a" | upper }}`, want: "stage-A"},
+		{name: "Base64", text: `{{ "user:pass" | b64enc }} {{ "aGk=" | b64dec }}`, want: "dXNlcjpwYXNz hi"},
+		{name: "Strings", text: `{{ "  x  " | trim }}{{ "a-b" | replace "-" "_" }}{{ "v1.2" | trimPrefix "v" }}`, want: "xa_b1.2"},
+		{name: "Date", text: `{{ now | date "2006" }}`, want: strconv.Itoa(time.Now().Year())},
+		{name: "Unknown Function", text: `{{ nope }}`, wantErr: true},
+		{name: "Bad Syntax", text: `{{ .Environment `, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderTemplate("test", tt.text, data)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("renderTemplate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("renderTemplate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReader_ReadTemplates(t *testing.T) {
+	input := &Variables{
+		scope: scope{
+			Vars: EnvVars{{"BUCKET", "{{ .Environment }}-{{ .DC }}-assets"}},
+		},
+		File: "variables.yml",
+		Environments: map[string]Environment{
+			"dev": {
+				Dcs: map[string]DC{
+					"east": {scope: scope{Vars: EnvVars{{"BROKEN", "{{ .DC | nope }}"}}}},
+					"west": {scope: scope{Vars: EnvVars{{"PLAIN", "no templates"}}}},
+				},
+			},
+		},
+	}
+
+	reader, _ := NewReader(WithSkipVault(true), WithTemplates(true))
+	got, err := reader.Read(context.Background(), input, "dev", "west")
+	if err != nil {
+		t.Fatalf("Reader.Read() error = %v", err)
+	}
+	want := OutputList{
+		{Comment: "Global Variables"},
+		{Key: "BUCKET", Value: "dev-west-assets", Origin: "variables.yml"},
+		{Comment: "Environment: dev"},
+		{Comment: "Datacenter: west"},
+		{Key: "PLAIN", Value: "no templates", Origin: "variables.yml"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Reader.Read() = %v, want %v", got, want)
+	}
+
+	_, err = reader.Read(context.Background(), input, "dev", "east")
+	if err == nil || !strings.Contains(err.Error(), "variables.yml: template: environments.dev.dcs.east.vars.BROKEN") {
+		t.Errorf("Reader.Read() error = %v, want it to name the file and key", err)
+	}
+
+	// Templates are opt-in
+	reader, _ = NewReader(WithSkipVault(true))
+	got, _ = reader.Read(context.Background(), input, "dev", "west")
+	if got[1].Value != "{{ .Environment }}-{{ .DC }}-assets" {
+		t.Errorf("Reader.Read() without templates = %v", got[1].Value)
+	}
+}
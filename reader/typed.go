@@ -0,0 +1,209 @@
+package reader
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultSeparator joins the items of a list value, unless the scope's
+// separators give another for the variable
+const DefaultSeparator = ","
+
+// Separators map variables with list values to the string their items are
+// joined with
+type Separators map[string]string
+
+// nodeValue renders a YAML value as an environment variable. Booleans are
+// normalized to true or false, lists are joined with sep, and mappings are
+// encoded as JSON in the order they're written.
+func nodeValue(node *yaml.Node, sep string) (string, error) {
+	if node.Kind == yaml.AliasNode {
+		return nodeValue(node.Alias, sep)
+	}
+	switch node.Kind {
+	case yaml.ScalarNode:
+		switch node.Tag {
+		case "!!null":
+			return "", nil
+		case "!!bool":
+			var b bool
+			if err := node.Decode(&b); err != nil {
+				return "", err
+			}
+			return strconv.FormatBool(b), nil
+		}
+		return node.Value, nil
+	case yaml.SequenceNode:
+		items, err := listItems(node)
+		if err != nil {
+			return "", err
+		}
+		return strings.Join(items, sep), nil
+	case yaml.MappingNode:
+		return nodeJSON(node)
+	}
+	return "", fmt.Errorf("line %d: unsupported value", node.Line)
+}
+
+// listItems renders the items of a list value, encoding lists and mappings
+// in it as JSON
+func listItems(node *yaml.Node) ([]string, error) {
+	items := []string{}
+	for _, item := range node.Content {
+		var value string
+		var err error
+		if item.Kind == yaml.ScalarNode || item.Kind == yaml.AliasNode && item.Alias.Kind == yaml.ScalarNode {
+			value, err = nodeValue(item, DefaultSeparator)
+		} else {
+			value, err = nodeJSON(item)
+		}
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, value)
+	}
+	return items, nil
+}
+
+// nodeJSON encodes a YAML value as JSON, keeping the order of mappings
+func nodeJSON(node *yaml.Node) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := writeNodeJSON(buf, node); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func writeNodeJSON(buf *bytes.Buffer, node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.AliasNode:
+		return writeNodeJSON(buf, node.Alias)
+	case yaml.SequenceNode:
+		buf.WriteByte('[')
+		for i, item := range node.Content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeNodeJSON(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	case yaml.MappingNode:
+		buf.WriteByte('{')
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			key, err := json.Marshal(node.Content[i].Value)
+			if err != nil {
+				return err
+			}
+			buf.Write(key)
+			buf.WriteByte(':')
+			if err := writeNodeJSON(buf, node.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+	}
+	var value interface{}
+	if err := node.Decode(&value); err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	buf.Write(encoded)
+	return nil
+}
+
+// decodeLists collects the items of the list values in a scope's vars, so
+// they can be joined again with separators from other files and scopes
+func decodeLists(node *yaml.Node) ([]Named[[]string], error) {
+	varsNode := mappingValue(node, "vars")
+	if varsNode == nil || varsNode.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	var lists []Named[[]string]
+	for i := 0; i+1 < len(varsNode.Content); i += 2 {
+		value := varsNode.Content[i+1]
+		if value.Kind == yaml.AliasNode {
+			value = value.Alias
+		}
+		if value.Kind != yaml.SequenceNode {
+			continue
+		}
+		items, err := listItems(value)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, Named[[]string]{Name: varsNode.Content[i].Value, Value: items})
+	}
+	return lists, nil
+}
+
+// joinLists joins the list values in vars with the scope's separators. Lists
+// without one keep DefaultSeparator, which they were decoded with.
+func (s scope) joinLists(vars EnvVars) EnvVars {
+	vars = slices.Clone(vars)
+	for _, list := range s.lists {
+		sep, found := s.Separators[list.Name]
+		if !found {
+			continue
+		}
+		if _, found := lookup(vars, list.Name); found {
+			vars = setVar(vars, list.Name, strings.Join(list.Value, sep))
+		}
+	}
+	return vars
+}
+
+// secretValue renders a value read from Vault the same way as a value in
+// vars. Vault responses are decoded with json.Number, so numbers are written
+// with the digits Vault returned rather than rounded through a float64.
+func secretValue(value interface{}, sep string) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case json.Number:
+		return v.String()
+	case []interface{}:
+		items := []string{}
+		for _, item := range v {
+			switch item.(type) {
+			case []interface{}, map[string]interface{}:
+				encoded, _ := json.Marshal(item)
+				items = append(items, string(encoded))
+			default:
+				items = append(items, secretValue(item, sep))
+			}
+		}
+		return strings.Join(items, sep)
+	case map[string]interface{}:
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// get finds the separator for a variable's list values
+func (s Separators) get(name string) string {
+	if sep, found := s[name]; found {
+		return sep
+	}
+	return DefaultSeparator
+}
@@ -0,0 +1,157 @@
+package reader
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestEnvVars_UnmarshalTyped(t *testing.T) {
+	input := `
+separators:
+  PATHS: ":"
+vars:
+  PORT: 8080
+  RATIO: 0.5
+  DEBUG: True
+  EMPTY:
+  HOSTS: [a.example.com, b.example.com]
+  PATHS: [/usr/bin, /bin]
+  CONFIG:
+    name: app
+    ports: [80, 443]
+    tls: yes
+  MATRIX: [[1, 2], {a: b}]
+`
+	var got Variables
+	if err := yaml.Unmarshal([]byte(input), &got); err != nil {
+		t.Fatalf("yaml.Unmarshal() error = %v", err)
+	}
+	want := EnvVars{
+		{"PORT", "8080"},
+		{"RATIO", "0.5"},
+		{"DEBUG", "true"},
+		{"EMPTY", ""},
+		{"HOSTS", "a.example.com,b.example.com"},
+		{"PATHS", "/usr/bin:/bin"},
+		{"CONFIG", `{"name":"app","ports":[80,443],"tls":"yes"}`},
+		{"MATRIX", `[1,2],{"a":"b"}`},
+	}
+	if !reflect.DeepEqual(got.Vars, want) {
+		t.Errorf("Variables.UnmarshalYAML() vars = %v, want %v", got.Vars, want)
+	}
+}
+
+func TestLoadVariables_MergedSeparators(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"base.yml": `
+separators:
+  PATHS: ":"
+environments:
+  base:
+    separators:
+      HOSTS: " "
+    vars:
+      HOSTS: [a, b]
+`,
+		"variables.yml": `
+include: [base.yml]
+vars:
+  PATHS: [/usr/bin, /bin]
+environments:
+  stage:
+    extends: base
+    vars:
+      HOSTS: [c, d]
+  plain:
+    extends: base
+    vars:
+      HOSTS: "e f"
+    separators:
+      HOSTS: ";"
+`,
+	})
+	input, err := LoadVariables(filepath.Join(dir, "variables.yml"))
+	if err != nil {
+		t.Fatalf("LoadVariables() error = %v", err)
+	}
+	reader, _ := NewReader(WithSkipVault(true))
+
+	tests := []struct {
+		name string
+		env  string
+		want []string
+	}{
+		{
+			name: "Included Separator",
+			want: []string{"PATHS=/usr/bin:/bin"},
+		},
+		{
+			name: "Extended Separator",
+			env:  "stage",
+			want: []string{"PATHS=/usr/bin:/bin", "HOSTS=c d"},
+		},
+		{
+			name: "Not A List",
+			env:  "plain",
+			want: []string{"PATHS=/usr/bin:/bin", "HOSTS=e f"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := reader.Read(context.Background(), input, tt.env, "")
+			if err != nil {
+				t.Fatalf("Reader.Read() error = %v", err)
+			}
+			got := []string{}
+			for _, o := range out {
+				if o.Key != "" {
+					got = append(got, o.Key+"="+o.Value)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Reader.Read() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecretValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		sep   string
+		want  string
+	}{
+		{name: "string", value: `"text"`, sep: ",", want: "text"},
+		{name: "integer", value: `8080`, sep: ",", want: "8080"},
+		{name: "float", value: `0.25`, sep: ",", want: "0.25"},
+		{name: "large number", value: `12345678901`, sep: ",", want: "12345678901"},
+		{name: "larger than a float64 holds", value: `12345678901234567`, sep: ",", want: "12345678901234567"},
+		{name: "exponent", value: `1e21`, sep: ",", want: "1e21"},
+		{name: "boolean", value: `false`, sep: ",", want: "false"},
+		{name: "null", value: `null`, sep: ",", want: ""},
+		{name: "list", value: `["a", 1, true, {"b": 2}]`, sep: " ", want: `a 1 true {"b":2}`},
+		{name: "list of large numbers", value: `[12345678901234567, {"b": 12345678901234567}]`, sep: " ", want: `12345678901234567 {"b":12345678901234567}`},
+		{name: "map", value: `{"b": [1], "a": "x"}`, sep: ",", want: `{"a":"x","b":[1]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Decoded like vault-client-go decodes responses
+			decoder := json.NewDecoder(strings.NewReader(tt.value))
+			decoder.UseNumber()
+			var value interface{}
+			if err := decoder.Decode(&value); err != nil {
+				t.Fatal(err)
+			}
+			if got := secretValue(value, tt.sep); got != tt.want {
+				t.Errorf("secretValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
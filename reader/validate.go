@@ -0,0 +1,554 @@
+package reader
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Problem is something wrong with a variables file, at a line and column
+type Problem struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Column == 0 {
+		return fmt.Sprintf("%s:%d: %s", p.File, p.Line, p.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", p.File, p.Line, p.Column, p.Message)
+}
+
+// ValidationError is returned when a variables file has problems
+type ValidationError []Problem
+
+func (v ValidationError) Error() string {
+	problems := []string{}
+	for _, problem := range v {
+		problems = append(problems, problem.String())
+	}
+	return strings.Join(problems, "\n")
+}
+
+// The fields allowed at each level of a variables file
+var (
+	scopeFields       = []string{"vars", "separators", "env_files", "file_vars", "exec_vars", "files", "required", "from_env", "secrets", "kv_secrets", "kv1_secrets", "scopes"}
+	variablesFields   = append([]string{"include", "dimensions", "default_environment", "default_dc", "environments"}, scopeFields...)
+	narrowFields      = append([]string{"unset"}, scopeFields...)
+	environmentFields = append([]string{"extends", "dcs"}, narrowFields...)
+	dcFields          = append([]string{"extends"}, narrowFields...)
+	kvSecretFields    = []string{"path", "optional", "default", "vars"}
+	secretVarFields   = []string{"key", "optional", "default"}
+	fileVarFields     = []string{"path", "format", "vars"}
+	execVarFields     = []string{"command", "timeout", "secret"}
+	fileValueFields   = []string{"value", "path", "key", "name", "base64"}
+)
+
+var yaml_line_regexp = regexp.MustCompile(`line (\d+)`)
+
+// ValidateFile checks a variables file and every file it includes, returning
+// all of the problems found. A path ending in "?" is optional.
+func ValidateFile(path string) []Problem {
+	return ValidateFileFormat(path, "")
+}
+
+// ValidateFileFormat checks a variables file like ValidateFile, reading it in
+// format rather than the one its extension suggests. Included files are
+// still detected from their extensions.
+func ValidateFileFormat(path string, format string) []Problem {
+	path, isOptional := strings.CutSuffix(path, "?")
+	if isRemote(path) {
+		// Definitions in Vault are validated when they're read
+		return nil
+	}
+	if _, err := os.Stat(path); isOptional && errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return validateFile(path, format, map[string]bool{})
+}
+
+// ValidateMergedFormat loads variables files in format, to check what only
+// shows once they're merged, such as extends. Definitions in Vault are left
+// out, as they're validated when they're read to build an environment.
+func ValidateMergedFormat(format string, paths ...string) error {
+	_, err := (&loader{strict: true, format: format, skipRemote: true}).loadFiles(paths)
+	return err
+}
+
+func validateFile(path string, format string, visited map[string]bool) []Problem {
+	absPath, err := filepath.Abs(path)
+	if err == nil {
+		if visited[absPath] {
+			return nil
+		}
+		visited[absPath] = true
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return []Problem{{File: path, Message: err.Error()}}
+	}
+	if format == "" {
+		format = fileFormat(path)
+	}
+	doc, err := parseDocument(raw, format)
+	if err != nil {
+		return []Problem{parseProblem(path, err)}
+	}
+	problems := Validate(path, doc)
+
+	// Includes are only followed when they're well formed
+	if root := documentRoot(doc); root != nil {
+		if include := mappingValue(root, "include"); include != nil && include.Kind == yaml.SequenceNode {
+			for _, item := range include.Content {
+				included := includePath(path, item.Value)
+				if isRemote(included) {
+					continue
+				}
+				problems = append(problems, validateFile(included, "", visited)...)
+			}
+		}
+	}
+	return problems
+}
+
+// parseProblem turns a YAML syntax error into a problem, keeping its line
+func parseProblem(file string, err error) Problem {
+	var posErr *positionError
+	if errors.As(err, &posErr) {
+		return Problem{File: file, Line: posErr.line, Column: posErr.column, Message: posErr.message}
+	}
+	problem := Problem{File: file, Message: strings.TrimPrefix(err.Error(), "yaml: ")}
+	if match := yaml_line_regexp.FindStringSubmatch(err.Error()); match != nil {
+		problem.Line, _ = strconv.Atoi(match[1])
+		problem.Message = strings.TrimPrefix(problem.Message, match[0]+": ")
+	}
+	return problem
+}
+
+func documentRoot(doc *yaml.Node) *yaml.Node {
+	if doc.Kind == 0 {
+		return nil
+	}
+	if doc.Kind == yaml.DocumentNode {
+		if len(doc.Content) == 0 {
+			return nil
+		}
+		return resolveAlias(doc.Content[0])
+	}
+	return resolveAlias(doc)
+}
+
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node != nil && node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
+
+// Validate checks a parsed variables file against the schema: only known
+// fields, the right types, and variable names that are valid in a shell
+func Validate(file string, doc *yaml.Node) []Problem {
+	v := &validator{file: file}
+	root := documentRoot(doc)
+	if root == nil || (root.Kind == yaml.ScalarNode && root.Tag == "!!null") {
+		return nil
+	}
+	v.fields(root, "the variables file", variablesFields, func(key string, value *yaml.Node) {
+		switch key {
+		case "include":
+			v.stringList(value, "include")
+		case "dimensions":
+			v.stringList(value, "dimensions")
+		case "default_environment", "default_dc":
+			v.kind(value, yaml.ScalarNode, key)
+		case "environments":
+			v.named(value, "environments", func(env *yaml.Node) {
+				v.fields(env, "an environment", environmentFields, func(key string, value *yaml.Node) {
+					switch key {
+					case "extends":
+						v.extends(value)
+					case "dcs":
+						v.dcPatterns(value)
+						v.named(value, "dcs", func(dc *yaml.Node) {
+							v.fields(dc, "a datacenter", dcFields, func(key string, value *yaml.Node) {
+								if key == "extends" {
+									v.extends(value)
+									return
+								}
+								v.scopeField(key, value)
+							})
+						})
+					default:
+						v.scopeField(key, value)
+					}
+				})
+			})
+		default:
+			v.scopeField(key, value)
+		}
+	})
+	return v.problems
+}
+
+type validator struct {
+	file     string
+	problems []Problem
+}
+
+func (v *validator) add(node *yaml.Node, format string, args ...interface{}) {
+	v.problems = append(v.problems, Problem{
+		File:    v.file,
+		Line:    node.Line,
+		Column:  node.Column,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// kind checks the kind of a node. Empty values are allowed, but not checked
+// any further.
+func (v *validator) kind(node *yaml.Node, kind yaml.Kind, what string) bool {
+	if node.Kind == kind {
+		return true
+	}
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return false
+	}
+	names := map[yaml.Kind]string{
+		yaml.MappingNode:  "a mapping",
+		yaml.SequenceNode: "a list",
+		yaml.ScalarNode:   "a value",
+	}
+	v.add(node, "%s must be %s", what, names[kind])
+	return false
+}
+
+// fields checks that a mapping only has known, unique fields, calling check
+// for each of them
+func (v *validator) fields(node *yaml.Node, what string, known []string, check func(key string, value *yaml.Node)) {
+	node = resolveAlias(node)
+	if !v.kind(node, yaml.MappingNode, what) {
+		return
+	}
+	seen := map[string]bool{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], resolveAlias(node.Content[i+1])
+		if key.Value == "<<" {
+			continue
+		}
+		if !slices.Contains(known, key.Value) {
+			v.add(key, "unknown field %q in %s", key.Value, what)
+			continue
+		}
+		if seen[key.Value] {
+			v.add(key, "duplicate field %q in %s", key.Value, what)
+			continue
+		}
+		seen[key.Value] = true
+		check(key.Value, value)
+	}
+}
+
+// named checks a mapping of names to values, such as environments
+func (v *validator) named(node *yaml.Node, what string, check func(*yaml.Node)) {
+	if !v.kind(node, yaml.MappingNode, what) {
+		return
+	}
+	seen := map[string]bool{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i]
+		if seen[key.Value] {
+			v.add(key, "duplicate name %q in %s", key.Value, what)
+		}
+		seen[key.Value] = true
+		check(resolveAlias(node.Content[i+1]))
+	}
+}
+
+// dcPatterns checks datacenter names, which can be glob patterns
+func (v *validator) dcPatterns(node *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if _, err := path.Match(node.Content[i].Value, ""); err != nil {
+			v.add(node.Content[i], "datacenter %q is not a valid pattern", node.Content[i].Value)
+		}
+	}
+}
+
+func (v *validator) scopeField(key string, value *yaml.Node) {
+	switch key {
+	case "vars":
+		v.vars(value)
+	case "separators":
+		v.variableMap(value, "separators", "a string")
+	case "env_files":
+		v.stringList(value, "env_files")
+	case "file_vars":
+		v.blocks(value, "file_vars", fileVarFields)
+	case "exec_vars":
+		v.execVars(value)
+	case "files":
+		v.files(value)
+	case "required":
+		v.nameList(value, "required")
+	case "from_env":
+		v.variableMap(value, "from_env", "the name of a variable")
+	case "secrets":
+		v.variableMap(value, "secrets", "a Vault path")
+	case "kv_secrets":
+		v.blocks(value, "kv_secrets", kvSecretFields)
+	case "kv1_secrets":
+		v.blocks(value, "kv1_secrets", kvSecretFields)
+	case "unset":
+		v.nameList(value, "unset")
+	case "scopes":
+		v.named(value, "scopes", func(dimension *yaml.Node) {
+			v.named(dimension, "a dimension of scopes", func(s *yaml.Node) {
+				v.fields(s, "a scope", narrowFields, v.scopeField)
+			})
+		})
+	}
+}
+
+func (v *validator) varName(key *yaml.Node) {
+	if !shellvar_regexp.MatchString(key.Value) {
+		v.add(key, "invalid variable name %q: names must start with a letter or _ and contain only letters, numbers and _", key.Value)
+	}
+}
+
+func (v *validator) vars(node *yaml.Node) {
+	if !v.kind(node, yaml.MappingNode, "vars") {
+		return
+	}
+	seen := map[string]bool{}
+	for i := 0; i < len(node.Content); i += 2 {
+		v.varName(node.Content[i])
+		v.unique(node.Content[i], seen, "vars")
+	}
+}
+
+// variableMap checks a mapping of variable names to strings
+func (v *validator) variableMap(node *yaml.Node, what string, valueName string) {
+	if !v.kind(node, yaml.MappingNode, what) {
+		return
+	}
+	seen := map[string]bool{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], resolveAlias(node.Content[i+1])
+		v.varName(key)
+		v.unique(key, seen, what)
+		if value.Kind != yaml.ScalarNode || value.Value == "" {
+			v.add(value, "%s in %s must be %s", key.Value, what, valueName)
+		}
+	}
+}
+
+// unique checks that a variable is only set once in a mapping
+func (v *validator) unique(key *yaml.Node, seen map[string]bool, what string) {
+	if seen[key.Value] {
+		v.add(key, "%s is set more than once in %s", key.Value, what)
+	}
+	seen[key.Value] = true
+}
+
+func (v *validator) stringList(node *yaml.Node, what string) {
+	if !v.kind(node, yaml.SequenceNode, what) {
+		return
+	}
+	for _, item := range node.Content {
+		item = resolveAlias(item)
+		if item.Kind != yaml.ScalarNode || item.Value == "" {
+			v.add(item, "entries of %s must be strings", what)
+		}
+	}
+}
+
+// nameList checks a list of variable names
+func (v *validator) nameList(node *yaml.Node, what string) {
+	if !v.kind(node, yaml.SequenceNode, what) {
+		return
+	}
+	seen := map[string]bool{}
+	for _, item := range node.Content {
+		item = resolveAlias(item)
+		if item.Kind != yaml.ScalarNode {
+			v.add(item, "entries of %s must be variable names", what)
+			continue
+		}
+		v.varName(item)
+		if seen[item.Value] {
+			v.add(item, "%s is listed more than once in %s", item.Value, what)
+		}
+		seen[item.Value] = true
+	}
+}
+
+func (v *validator) extends(node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode {
+		return
+	}
+	v.stringList(node, "extends")
+}
+
+// blocks checks kv_secrets, kv1_secrets and file_vars, which are lists of a
+// path and the variables to read from it
+func (v *validator) blocks(node *yaml.Node, what string, known []string) {
+	if !v.kind(node, yaml.SequenceNode, what) {
+		return
+	}
+	for _, block := range node.Content {
+		block = resolveAlias(block)
+		hasPath := false
+		v.fields(block, "an entry of "+what, known, func(key string, value *yaml.Node) {
+			switch key {
+			case "path":
+				hasPath = v.kind(value, yaml.ScalarNode, "path") && value.Value != ""
+			case "format":
+				if !slices.Contains([]string{"json", "yaml", "yml", "toml"}, value.Value) {
+					v.add(value, "format must be json, yaml or toml")
+				}
+			case "optional", "default":
+				v.secretOption(key, value)
+			case "vars":
+				if what == "file_vars" {
+					v.variableMap(value, what+" vars", "a key")
+				} else {
+					v.secretVars(value, what+" vars")
+				}
+			}
+		})
+		if block.Kind == yaml.MappingNode && !hasPath {
+			v.add(block, "an entry of %s needs a path", what)
+		}
+	}
+}
+
+// secretVars checks the vars of a kv_secrets block, which are keys or a
+// mapping of a key and its options
+func (v *validator) secretVars(node *yaml.Node, what string) {
+	if !v.kind(node, yaml.MappingNode, what) {
+		return
+	}
+	seen := map[string]bool{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], resolveAlias(node.Content[i+1])
+		v.varName(key)
+		v.unique(key, seen, what)
+		if value.Kind != yaml.MappingNode {
+			if value.Kind != yaml.ScalarNode || value.Value == "" {
+				v.add(value, "%s in %s must be a key", key.Value, what)
+			}
+			continue
+		}
+		hasKey := false
+		v.fields(value, "a secret", secretVarFields, func(field string, fieldValue *yaml.Node) {
+			if field == "key" {
+				hasKey = v.kind(fieldValue, yaml.ScalarNode, "key") && fieldValue.Value != ""
+				return
+			}
+			v.secretOption(field, fieldValue)
+		})
+		if !hasKey {
+			v.add(value, "%s in %s needs a key", key.Value, what)
+		}
+	}
+}
+
+func (v *validator) secretOption(field string, value *yaml.Node) {
+	switch field {
+	case "optional":
+		if value.Tag != "!!bool" {
+			v.add(value, "optional must be true or false")
+		}
+	case "default":
+		v.kind(value, yaml.ScalarNode, "default")
+	}
+}
+
+func (v *validator) execVars(node *yaml.Node) {
+	if !v.kind(node, yaml.MappingNode, "exec_vars") {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], resolveAlias(node.Content[i+1])
+		v.varName(key)
+		if value.Kind == yaml.ScalarNode {
+			continue
+		}
+		hasCommand := false
+		v.fields(value, "an exec_vars entry", execVarFields, func(field string, fieldValue *yaml.Node) {
+			switch field {
+			case "command":
+				hasCommand = v.kind(fieldValue, yaml.ScalarNode, "command") && fieldValue.Value != ""
+			case "timeout":
+				// A bare number would be read as nanoseconds, so only duration strings are accepted
+				if _, err := time.ParseDuration(fieldValue.Value); err != nil || fieldValue.Tag != "!!str" {
+					v.add(fieldValue, "timeout must be a duration such as 30s")
+				}
+			case "secret":
+				if fieldValue.Tag != "!!bool" {
+					v.add(fieldValue, "secret must be true or false")
+				}
+			}
+		})
+		if value.Kind == yaml.MappingNode && !hasCommand {
+			v.add(value, "exec_vars entry %s needs a command", key.Value)
+		}
+	}
+}
+
+func (v *validator) files(node *yaml.Node) {
+	if !v.kind(node, yaml.MappingNode, "files") {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], resolveAlias(node.Content[i+1])
+		v.varName(key)
+		if value.Kind == yaml.ScalarNode {
+			continue
+		}
+		hasValue, hasPath, hasKey := false, false, false
+		v.fields(value, "a files entry", fileValueFields, func(field string, fieldValue *yaml.Node) {
+			switch field {
+			case "value":
+				hasValue = v.kind(fieldValue, yaml.ScalarNode, "value")
+			case "path":
+				hasPath = v.kind(fieldValue, yaml.ScalarNode, "path") && fieldValue.Value != ""
+			case "key":
+				hasKey = true
+				v.kind(fieldValue, yaml.ScalarNode, "key")
+			case "name":
+				if v.kind(fieldValue, yaml.ScalarNode, "name") && (fieldValue.Value != filepath.Base(fieldValue.Value) || fieldValue.Value == "." || fieldValue.Value == "..") {
+					v.add(fieldValue, "name must be a file name without a directory")
+				}
+			case "base64":
+				if fieldValue.Tag != "!!bool" {
+					v.add(fieldValue, "base64 must be true or false")
+				}
+			}
+		})
+		if value.Kind != yaml.MappingNode {
+			continue
+		}
+		if hasValue == hasPath {
+			v.add(value, "files entry %s needs either a value or a path", key.Value)
+		}
+		if hasKey && !hasPath {
+			v.add(value, "files entry %s has a key but no path", key.Value)
+		}
+	}
+}
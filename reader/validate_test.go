@@ -0,0 +1,246 @@
+package reader
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want []string
+	}{
+		{
+			name: "valid",
+			yaml: `
+include: [shared.yml]
+dimensions: [region]
+vars:
+  PLAIN: "value"
+  NUMBER: 5
+  SECRET: !encrypted abc
+env_files: [.env]
+exec_vars:
+  SHORT: "date"
+  LONG:
+    command: "date"
+    timeout: 5s
+    secret: true
+kv_secrets:
+  - path: "secret/test"
+    optional: true
+    vars:
+      KV: "key"
+      FALLBACK:
+        key: "other"
+        default: ""
+file_vars:
+  - path: "config.json"
+    format: json
+    vars:
+      HOST: "db.host"
+scopes:
+  region:
+    us-east:
+      vars:
+        REGION: "us-east-1"
+environments:
+  stage:
+    extends: base
+    vars:
+    dcs:
+      east:
+        extends: [west]
+        unset: [HTTP_PROXY]
+        secrets:
+          OLD: "gen/test"
+`,
+		},
+		{
+			name: "empty",
+			yaml: "",
+		},
+		{
+			name: "unknown fields",
+			yaml: "kv_secret:\n  - path: secret/test\nenvironments:\n  stage:\n    dc:\n      east: {}\n",
+			want: []string{
+				`test.yml:1:1: unknown field "kv_secret" in the variables file`,
+				`test.yml:5:5: unknown field "dc" in an environment`,
+			},
+		},
+		{
+			name: "types",
+			yaml: "vars: [a]\nenv_files: .env\nenvironments:\n  stage:\n    separators:\n      LIST: [\",\"]\n",
+			want: []string{
+				`test.yml:1:7: vars must be a mapping`,
+				`test.yml:2:12: env_files must be a list`,
+				`test.yml:6:13: LIST in separators must be a string`,
+			},
+		},
+		{
+			name: "variable names",
+			yaml: "vars:\n  \"export hi=there\": \"hi\"\nsecrets:\n  1BAD: \"gen/test\"\n",
+			want: []string{
+				`test.yml:2:3: invalid variable name "export hi=there": names must start with a letter or _ and contain only letters, numbers and _`,
+				`test.yml:4:3: invalid variable name "1BAD": names must start with a letter or _ and contain only letters, numbers and _`,
+			},
+		},
+		{
+			name: "optional secrets",
+			yaml: "kv1_secrets:\n  - path: kv/test\n    optional: yes please\n    vars:\n      A:\n        default: [a]\n",
+			want: []string{
+				`test.yml:3:15: optional must be true or false`,
+				`test.yml:6:18: default must be a value`,
+				`test.yml:6:9: A in kv1_secrets vars needs a key`,
+			},
+		},
+		{
+			name: "unset",
+			yaml: "unset: [A]\nenvironments:\n  stage:\n    unset: [B, 1B, B]\n",
+			want: []string{
+				`test.yml:1:1: unknown field "unset" in the variables file`,
+				`test.yml:4:16: invalid variable name "1B": names must start with a letter or _ and contain only letters, numbers and _`,
+				`test.yml:4:20: B is listed more than once in unset`,
+			},
+		},
+		{
+			name: "blocks",
+			yaml: "kv_secrets:\n  - vars:\n      A: a\nfile_vars:\n  - path: x.ini\n    format: ini\nexec_vars:\n  A:\n    timeout: soon\n",
+			want: []string{
+				`test.yml:2:5: an entry of kv_secrets needs a path`,
+				`test.yml:6:13: format must be json, yaml or toml`,
+				`test.yml:9:14: timeout must be a duration such as 30s`,
+				`test.yml:9:5: exec_vars entry A needs a command`,
+			},
+		},
+		{
+			name: "timeout without a unit",
+			yaml: "exec_vars:\n  A:\n    command: date\n    timeout: 30\n",
+			want: []string{
+				`test.yml:4:14: timeout must be a duration such as 30s`,
+			},
+		},
+		{
+			name: "selection",
+			yaml: "default_environment: [stage]\ndefault_dc: us-east\nenvironments:\n  stage:\n    dcs:\n      us-*: {}\n      \"us-[\": {}\n",
+			want: []string{
+				`test.yml:1:22: default_environment must be a value`,
+				`test.yml:7:7: datacenter "us-[" is not a valid pattern`,
+			},
+		},
+		{
+			name: "files",
+			yaml: "files:\n  A: literal\n  B:\n    name: ../b\n    base64: yes\n  C:\n    value: c\n    key: k\n",
+			want: []string{
+				`test.yml:4:11: name must be a file name without a directory`,
+				`test.yml:5:13: base64 must be true or false`,
+				`test.yml:4:5: files entry B needs either a value or a path`,
+				`test.yml:7:5: files entry C has a key but no path`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc yaml.Node
+			if err := yaml.Unmarshal([]byte(tt.yaml), &doc); err != nil {
+				t.Fatalf("yaml.Unmarshal() error = %v", err)
+			}
+			got := []string{}
+			for _, problem := range Validate("test.yml", &doc) {
+				got = append(got, problem.String())
+			}
+			if tt.want == nil {
+				tt.want = []string{}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateFile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"variables.yml": "include: [shared.yml]\nvars:\n  A: a\n",
+		"shared.yml":    "vars:\n  B: b\nsecret:\n  C: c\n",
+		"broken.yml":    "vars:\n  A: [\n",
+		"remote.yml":    "include: [\"vault://secret/buildenv/base\"]\nvars:\n  A: a\n",
+	})
+
+	want := []Problem{{File: filepath.Join(dir, "shared.yml"), Line: 3, Column: 1, Message: `unknown field "secret" in the variables file`}}
+	if got := ValidateFile(filepath.Join(dir, "variables.yml")); !reflect.DeepEqual(got, want) {
+		t.Errorf("ValidateFile() = %v, want %v", got, want)
+	}
+	if got := ValidateFile(filepath.Join(dir, "missing.yml?")); got != nil {
+		t.Errorf("ValidateFile() of a missing optional file = %v, want nil", got)
+	}
+	if got := ValidateFile(filepath.Join(dir, "broken.yml")); len(got) != 1 || got[0].Line != 2 {
+		t.Errorf("ValidateFile() of broken YAML = %v, want one problem on line 2", got)
+	}
+
+	// Definitions in Vault are only validated when they're read
+	if got := ValidateFile(filepath.Join(dir, "remote.yml")); got != nil {
+		t.Errorf("ValidateFile() with an include in Vault = %v, want nil", got)
+	}
+	if got := ValidateFile("vault://secret/buildenv/app"); got != nil {
+		t.Errorf("ValidateFile() of a definition in Vault = %v, want nil", got)
+	}
+	if err := ValidateMergedFormat("", filepath.Join(dir, "remote.yml"), "vault://secret/buildenv/app?"); err != nil {
+		t.Errorf("ValidateMergedFormat() with definitions in Vault error = %v", err)
+	}
+
+	_, err := LoadVariables(filepath.Join(dir, "variables.yml"))
+	var validationErr ValidationError
+	if !errors.As(err, &validationErr) || !reflect.DeepEqual([]Problem(validationErr), want) {
+		t.Errorf("LoadVariables() error = %v, want %v", err, want)
+	}
+}
+
+// The published JSON Schema should allow the same fields as the validator
+func TestSchemaFields(t *testing.T) {
+	raw, err := os.ReadFile("../variables.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema struct {
+		Properties  map[string]interface{} `json:"properties"`
+		Definitions map[string]struct {
+			Properties map[string]interface{} `json:"properties"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		t.Fatalf("invalid schema: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		properties map[string]interface{}
+		fields     []string
+	}{
+		{name: "variables", properties: schema.Properties, fields: variablesFields},
+		{name: "environment", properties: schema.Definitions["environment"].Properties, fields: environmentFields},
+		{name: "dc", properties: schema.Definitions["dc"].Properties, fields: dcFields},
+		{name: "scope", properties: schema.Definitions["scope"].Properties, fields: narrowFields},
+		{name: "kvSecrets", properties: schema.Definitions["kvSecrets"].Properties, fields: kvSecretFields},
+		{name: "secretVar", properties: schema.Definitions["secretVar"].Properties, fields: secretVarFields},
+		{name: "fileVars", properties: schema.Definitions["fileVars"].Properties, fields: fileVarFields},
+		{name: "execVar", properties: schema.Definitions["execVar"].Properties, fields: execVarFields},
+		{name: "fileValue", properties: schema.Definitions["fileValue"].Properties, fields: fileValueFields},
+	}
+	for _, tt := range tests {
+		got := sortedKeys(tt.properties)
+		want := slices.Clone(tt.fields)
+		slices.Sort(want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("schema %s properties = %v, want %v", tt.name, got, want)
+		}
+	}
+}